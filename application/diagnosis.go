@@ -0,0 +1,65 @@
+package application
+
+import (
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+)
+
+const (
+	// HeaderDebug 请求携带该请求头时，即使未开启诊断模式也会进行诊断
+	HeaderDebug = "X-Deepmock-Debug"
+	// HeaderDiagnosis 诊断摘要的响应头
+	HeaderDiagnosis = "X-Deepmock-Diagnosis"
+)
+
+type (
+	// UnmatchedError 诊断模式下未命中规则时返回的错误，包含诊断结果
+	UnmatchedError struct {
+		Diagnosis *types.DiagnosisDTO
+	}
+)
+
+// Error error的实现
+func (e *UnmatchedError) Error() string {
+	return ErrRuleNotFound.Error()
+}
+
+// Unwrap 支持errors.Is(err, ErrRuleNotFound)
+func (e *UnmatchedError) Unwrap() error {
+	return ErrRuleNotFound
+}
+
+// WithDiagnostics 开启诊断模式，对未命中规则或者回落到默认regulation的请求给出最接近的规则及原因
+func (srv *mockApplication) WithDiagnostics(enabled bool) {
+	srv.diagnose = enabled
+}
+
+func (srv *mockApplication) diagnosing(ctx *fasthttp.RequestCtx) bool {
+	return srv.diagnose || len(ctx.Request.Header.Peek(HeaderDebug)) > 0
+}
+
+func convertDiagnosis(d *domain.Diagnosis) *types.DiagnosisDTO {
+	if d == nil {
+		return nil
+	}
+	dto := &types.DiagnosisDTO{
+		Fallback:   d.Fallback,
+		RuleID:     d.RuleID,
+		Summary:    d.Summary(),
+		Candidates: make([]*types.RuleMissDTO, len(d.Candidates)),
+	}
+	for i, candidate := range d.Candidates {
+		miss := &types.RuleMissDTO{
+			RuleID:  candidate.RuleID,
+			Method:  candidate.Method,
+			Path:    candidate.Path,
+			Reasons: candidate.Reasons,
+		}
+		for _, reg := range candidate.Regulations {
+			miss.Regulations = append(miss.Regulations, &types.RegulationMissDTO{Index: reg.Index, Reasons: reg.Reasons})
+		}
+		dto.Candidates[i] = miss
+	}
+	return dto
+}
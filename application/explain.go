@@ -0,0 +1,113 @@
+package application
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+)
+
+var explainRemoteAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+func convertExplainRequestDTO(dto *types.ExplainRequestDTO, req *fasthttp.Request) error {
+	if dto.Method == "" || dto.Path == "" {
+		return errors.New("missing method or path")
+	}
+	for k, v := range dto.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.SetMethod(dto.Method)
+	uri := dto.Path
+	if dto.Query != "" {
+		uri += "?" + dto.Query
+	}
+	req.SetRequestURI(uri)
+
+	if dto.B64EncodeBody != "" {
+		body, err := base64.StdEncoding.DecodeString(dto.B64EncodeBody)
+		if err != nil {
+			return err
+		}
+		req.SetBody(body)
+	} else {
+		req.SetBodyString(dto.Body)
+	}
+	return nil
+}
+
+func convertResponse(resp *fasthttp.Response) *types.ExplainResponseDTO {
+	dto := &types.ExplainResponseDTO{StatusCode: resp.StatusCode(), Header: map[string]string{}}
+	resp.Header.VisitAll(func(key, value []byte) {
+		dto.Header[string(key)] = string(value)
+	})
+	if body := resp.Body(); utf8.Valid(body) {
+		dto.Body = string(body)
+	} else {
+		dto.B64EncodeBody = base64.StdEncoding.EncodeToString(body)
+	}
+	return dto
+}
+
+// Explain 在上下文中的命名空间演练请求的匹配及渲染过程的user case，不会修改计数器、请求日志及场景状态
+func (srv *mockApplication) Explain(ctx context.Context, dto *types.ExplainRequestDTO) (*types.ExplainResultDTO, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	if err := convertExplainRequestDTO(dto, req); err != nil {
+		return nil, err
+	}
+
+	// 与MockAPI的匹配顺序一致，但不经过执行器缓存
+	var exec *domain.Executor
+	executors := srv.listExecutors(ctx, namespaceFromContext(ctx))
+	for _, candidate := range executors {
+		if candidate.Match(req.URI().Path(), req.Header.Method()) && candidate.Available(ctx, srv.scenario) {
+			exec = candidate
+			break
+		}
+	}
+	res := &types.ExplainResultDTO{RegulationIndex: -1}
+	if exec == nil {
+		res.Diagnosis = convertDiagnosis(domain.DiagnoseUnmatched(ctx, executors, req, srv.scenario))
+		return res, nil
+	}
+
+	res.Matched, res.RuleID = true, exec.ID
+	for _, evaluation := range exec.EvaluateRegulations(ctx, req, srv.scenario) {
+		res.Regulations = append(res.Regulations, &types.RegulationEvaluationDTO{
+			Index:             evaluation.Index,
+			IsDefault:         evaluation.IsDefault,
+			ScenarioSatisfied: evaluation.ScenarioSatisfied,
+			Matched:           evaluation.Matched,
+			Chosen:            evaluation.Chosen,
+			Reasons:           evaluation.Reasons,
+		})
+	}
+
+	regulation := exec.FindRegulationExecutor(ctx, req, srv.scenario)
+	res.RegulationIndex = exec.RegulationIndex(regulation)
+	if regulation == nil {
+		return res, nil
+	}
+
+	rc := new(fasthttp.RequestCtx)
+	rc.Init(req, explainRemoteAddr, nil)
+	params := exec.PathParams(req.URI().Path())
+	weight := exec.Weight.DiceAll()
+	delay := regulation.Delay(rc, exec.Variable, weight, params)
+	renderErr := regulation.Render(rc, exec.Variable, weight, params)
+
+	res.Response = convertResponse(&rc.Response)
+	res.Response.Delay = float64(delay.Microseconds()) / 1000
+	if fault := regulation.PickFault(); fault != domain.FaultNone {
+		res.Response.Fault = fault
+	}
+	if renderErr != nil {
+		res.Response.RenderError = renderErr.Error()
+	}
+	return res, nil
+}
@@ -0,0 +1,258 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+	"go.uber.org/zap"
+)
+
+type authorKey struct{}
+
+var (
+	// ErrHistoryUnavailable 未载入历史版本存储库
+	ErrHistoryUnavailable = errors.New("rule history is unavailable")
+)
+
+// WithHistory 载入规则历史版本存储库，每次修改规则都会保存一个历史版本
+func (srv *mockApplication) WithHistory(history domain.RuleHistoryRepository) {
+	srv.history = history
+}
+
+// ContextWithAuthor 返回携带修改人的上下文，修改规则时记录在历史版本中
+func ContextWithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+func authorFromContext(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey{}).(string)
+	return author
+}
+
+// saveRevision 保存历史版本，失败时只记录日志，不影响规则的修改
+func (srv *mockApplication) saveRevision(ctx context.Context, action domain.RevisionAction, rid string, rule *domain.Rule) {
+	if srv.history == nil {
+		return
+	}
+	rev := &domain.RuleRevision{RuleID: rid, Action: action, Author: authorFromContext(ctx), Rule: rule}
+	if err := srv.history.SaveRevision(ctx, rev); err != nil {
+		misc.Logger.Error("failed to save rule revision", zap.String("rule_id", rid), zap.String("action", string(action)), zap.Error(err))
+	}
+}
+
+func convertRuleRevision(rev *domain.RuleRevision) *types.RuleRevisionDTO {
+	r := &types.RuleRevisionDTO{
+		RuleID:   rev.RuleID,
+		Revision: rev.Revision,
+		Action:   string(rev.Action),
+		Author:   rev.Author,
+		CTime:    rev.CTime,
+	}
+	if rev.Rule != nil {
+		r.Version = rev.Rule.Version
+	}
+	return r
+}
+
+func revisionRuleDTO(rev *domain.RuleRevision) *types.RuleDTO {
+	if rev == nil || rev.Rule == nil {
+		return nil
+	}
+	return convertRuleEntity(rev.Rule)
+}
+
+// listRevisions 查询上下文命名空间中规则的所有历史版本，属于其他命名空间的规则视为不存在
+func (srv *mockApplication) listRevisions(ctx context.Context, rid string) ([]*domain.RuleRevision, error) {
+	revisions, err := srv.history.ListRevisions(ctx, rid)
+	if err != nil {
+		misc.Logger.Error("failed to list rule revisions", zap.String("rule_id", rid), zap.Error(err))
+		return nil, err
+	}
+	// 同一规则的所有版本属于同一命名空间，删除版本不记录规则内容
+	for _, rev := range revisions {
+		if rev.Rule == nil {
+			continue
+		}
+		if !inNamespace(ctx, rev.Rule) {
+			return nil, &domain.RuleNotExistError{RuleID: rid}
+		}
+		break
+	}
+	return revisions, nil
+}
+
+// ListRevisions 查询规则所有历史版本的user case，每个版本附带相对上一个版本的修改
+func (srv *mockApplication) ListRevisions(ctx context.Context, rid string) ([]*types.RuleRevisionDTO, error) {
+	if srv.history == nil {
+		return nil, ErrHistoryUnavailable
+	}
+	revisions, err := srv.listRevisions(ctx, rid)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*types.RuleRevisionDTO, len(revisions))
+	var previous *types.RuleDTO
+	for index, rev := range revisions {
+		current := revisionRuleDTO(rev)
+		res[index] = convertRuleRevision(rev)
+		res[index].Changes = diffRules(previous, current)
+		previous = current
+	}
+	return res, nil
+}
+
+// GetRevision 获取规则指定历史版本的user case
+func (srv *mockApplication) GetRevision(ctx context.Context, rid string, revision int) (*types.RuleRevisionDTO, error) {
+	if srv.history == nil {
+		return nil, ErrHistoryUnavailable
+	}
+	if _, err := srv.listRevisions(ctx, rid); err != nil {
+		return nil, err
+	}
+	rev, err := srv.history.GetRevision(ctx, rid, revision)
+	if err != nil {
+		misc.Logger.Error("failed to find rule revision", zap.String("rule_id", rid), zap.Int("revision", revision), zap.Error(err))
+		return nil, err
+	}
+	res := convertRuleRevision(rev)
+	res.Rule = revisionRuleDTO(rev)
+	return res, nil
+}
+
+// DiffRevisions 比较规则两个历史版本的user case
+func (srv *mockApplication) DiffRevisions(ctx context.Context, rid string, from, to int) (*types.RuleDiffDTO, error) {
+	if srv.history == nil {
+		return nil, ErrHistoryUnavailable
+	}
+	if _, err := srv.listRevisions(ctx, rid); err != nil {
+		return nil, err
+	}
+	var rules [2]*types.RuleDTO
+	for index, revision := range []int{from, to} {
+		rev, err := srv.history.GetRevision(ctx, rid, revision)
+		if err != nil {
+			misc.Logger.Error("failed to find rule revision", zap.String("rule_id", rid), zap.Int("revision", revision), zap.Error(err))
+			return nil, err
+		}
+		rules[index] = revisionRuleDTO(rev)
+	}
+	return &types.RuleDiffDTO{RuleID: rid, From: from, To: to, Changes: diffRules(rules[0], rules[1])}, nil
+}
+
+// Rollback 将规则回滚至指定历史版本的user case，回滚本身会产生一个新的历史版本；规则已被删除时重新创建
+func (srv *mockApplication) Rollback(ctx context.Context, rb *types.RollbackDTO) error {
+	if srv.history == nil {
+		return ErrHistoryUnavailable
+	}
+	if _, err := srv.listRevisions(ctx, rb.RuleID); err != nil {
+		return err
+	}
+	rev, err := srv.history.GetRevision(ctx, rb.RuleID, rb.Revision)
+	if err != nil {
+		misc.Logger.Error("failed to find rule revision", zap.String("rule_id", rb.RuleID), zap.Int("revision", rb.Revision), zap.Error(err))
+		return err
+	}
+	if rev.Rule == nil {
+		return errors.New("cannot rollback to a deleted revision: " + strconv.Itoa(rb.Revision))
+	}
+
+	current, err := srv.rule.GetRuleByID(ctx, rb.RuleID)
+	switch {
+	case errors.Is(err, domain.ErrRuleNotExist):
+		// 规则已被删除，指定了期望版本号时视为冲突
+		if expected, ok := ctx.Value(ifMatchKey{}).(int); ok {
+			return &domain.VersionConflictError{RuleID: rb.RuleID, Expected: expected}
+		}
+		rule := rev.Rule
+		rule.Version = 0
+		if err := srv.rule.CreateRule(ctx, rule); err != nil {
+			misc.Logger.Error("failed to recreate rule record", zap.String("rule_id", rb.RuleID), zap.Error(err))
+			return err
+		}
+	case err != nil:
+		misc.Logger.Error("failed to find rule record", zap.String("rule_id", rb.RuleID), zap.Error(err))
+		return err
+	default:
+		if err := checkIfMatch(ctx, current); err != nil {
+			return err
+		}
+		if err := current.Put(rev.Rule); err != nil {
+			misc.Logger.Error("failed to validate rule after rollback", zap.String("rule_id", rb.RuleID), zap.Error(err))
+			return err
+		}
+		if err := srv.rule.UpdateRule(ctx, current); err != nil {
+			misc.Logger.Error("failed to update rule record", zap.String("rule_id", rb.RuleID), zap.Error(err))
+			return err
+		}
+	}
+	misc.Logger.Info("rollback the rule record", zap.String("rule_id", rb.RuleID), zap.Int("revision", rb.Revision))
+	return srv.publish(ctx, domain.RevisionActionRollback, rb.RuleID)
+}
+
+// diffRules 按JSON路径比较两个规则，规则为nil时视为所有字段为空
+func diffRules(old, new *types.RuleDTO) []*types.FieldChangeDTO {
+	before, after := flattenRule(old), flattenRule(new)
+	fields := make([]string, 0, len(before)+len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, exists := before[field]; !exists {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := make([]*types.FieldChangeDTO, 0)
+	for _, field := range fields {
+		o, n := before[field], after[field]
+		if !reflect.DeepEqual(o, n) {
+			changes = append(changes, &types.FieldChangeDTO{Field: field, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+func flattenRule(rule *types.RuleDTO) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if rule == nil {
+		return fields
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fields
+	}
+	var v interface{}
+	if err = json.Unmarshal(data, &v); err != nil {
+		return fields
+	}
+	flatten("", v, fields)
+	return fields
+}
+
+func flatten(prefix string, v interface{}, fields map[string]interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, sub := range value {
+			if prefix == "" {
+				flatten(key, sub, fields)
+			} else {
+				flatten(prefix+"."+key, sub, fields)
+			}
+		}
+	case []interface{}:
+		for index, sub := range value {
+			flatten(prefix+"["+strconv.Itoa(index)+"]", sub, fields)
+		}
+	default:
+		fields[prefix] = value
+	}
+}
@@ -0,0 +1,48 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+)
+
+// unavailableRuleRepository 查询规则时总是返回err，模拟存储不可用
+type unavailableRuleRepository struct {
+	domain.RuleRepository
+	err error
+}
+
+func (rr *unavailableRuleRepository) GetRuleByID(context.Context, string) (*domain.Rule, error) {
+	return nil, rr.err
+}
+
+func TestMockApplication_RollbackDeleted(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	ctx := context.TODO()
+	rule := newTestRule("/a")
+	assert.NoError(t, srv.rule.CreateRule(ctx, rule))
+	assert.NoError(t, srv.publish(ctx, domain.RevisionActionCreate, rule.ID))
+	assert.NoError(t, srv.DeleteRule(ctx, rule.ID))
+
+	// 规则已被删除时，指定期望版本号视为冲突
+	err := srv.Rollback(ContextWithIfMatch(ctx, 1), &types.RollbackDTO{RuleID: rule.ID, Revision: 1})
+	assert.True(t, errors.Is(err, domain.ErrVersionConflict))
+
+	// 存储不可用时不重新创建规则
+	repo := srv.rule
+	srv.rule = &unavailableRuleRepository{RuleRepository: repo, err: context.DeadlineExceeded}
+	err = srv.Rollback(ctx, &types.RollbackDTO{RuleID: rule.ID, Revision: 1})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = repo.GetRuleByID(ctx, rule.ID)
+	assert.True(t, errors.Is(err, domain.ErrRuleNotExist))
+
+	srv.rule = repo
+	assert.NoError(t, srv.Rollback(ctx, &types.RollbackDTO{RuleID: rule.ID, Revision: 1}))
+	current, err := srv.GetRule(ctx, rule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "/a", current.Path)
+}
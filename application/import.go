@@ -0,0 +1,138 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// ImportModeUpsert 新增不存在的规则，覆盖已存在的规则，默认的导入模式
+	ImportModeUpsert = "upsert"
+	// ImportModeCreateOnly 只新增不存在的规则，跳过已存在的规则
+	ImportModeCreateOnly = "create_only"
+	// ImportModeReplaceAll 导入后只保留导入的规则，删除其他规则
+	ImportModeReplaceAll = "replace_all"
+)
+
+const (
+	importActionCreated   = "created"
+	importActionUpdated   = "updated"
+	importActionUnchanged = "unchanged"
+	importActionSkipped   = "skipped"
+	importActionDeleted   = "deleted"
+)
+
+// Import 将规则导入上下文中命名空间的user case，按mode计算每个规则的导入计划，dryRun为true时只返回计划而不写入
+func (srv *mockApplication) Import(ctx context.Context, mode string, dryRun bool, rules ...*types.RuleDTO) (*types.ImportPlanDTO, error) {
+	if mode == "" {
+		mode = ImportModeUpsert
+	}
+	if mode != ImportModeUpsert && mode != ImportModeCreateOnly && mode != ImportModeReplaceAll {
+		return nil, errors.New("unsupported import mode: " + mode)
+	}
+
+	namespace := namespaceFromContext(ctx)
+	imported := make([]*domain.Rule, len(rules))
+	seen := make(map[string]struct{}, len(rules))
+	for index, rule := range rules {
+		ru := convertRuleDTO(rule)
+		// 规则ID属于导出时的命名空间，导入其他命名空间时重新生成
+		if ru.ID == domain.GenRuleID(ru.Namespace, ru.Path, ru.Method) {
+			ru.ID = ""
+		}
+		ru.Namespace = namespace
+		if err := ru.Validate(); err != nil {
+			misc.Logger.Error("failed to validate rule content", zap.String("rule_id", rule.ID), zap.Error(err))
+			return nil, err
+		}
+		if _, exists := seen[ru.ID]; exists {
+			return nil, errors.New("duplicate rule in import: " + ru.ID)
+		}
+		seen[ru.ID] = struct{}{}
+		imported[index] = ru
+	}
+
+	current, err := srv.rule.Export(ctx)
+	if err != nil {
+		misc.Logger.Error("failed to export rules", zap.Error(err))
+		return nil, err
+	}
+	existing := make(map[string]*domain.Rule, len(current))
+	scoped := make([]*domain.Rule, 0, len(current))
+	for _, rule := range current {
+		if rule.Namespace == namespace {
+			existing[rule.ID] = rule
+			scoped = append(scoped, rule)
+		}
+	}
+
+	plan := &types.ImportPlanDTO{Namespace: namespace, Mode: mode, DryRun: dryRun, Summary: map[string]int{}}
+	var writes []*domain.Rule
+	var deleted []string
+	// 覆盖及删除的规则在写入时校验版本号，避免覆盖快照之后的修改
+	expected := map[string]int{}
+	for _, rule := range imported {
+		item := &types.ImportPlanItemDTO{ID: rule.ID, Path: rule.Path, Method: rule.Method}
+		old, exists := existing[rule.ID]
+		switch {
+		case !exists:
+			item.Action = importActionCreated
+			writes = append(writes, rule)
+		case mode == ImportModeCreateOnly:
+			item.Action = importActionSkipped
+		default:
+			item.Changes = diffRules(convertRuleEntity(old), convertRuleEntity(rule))
+			if len(item.Changes) == 0 {
+				item.Action = importActionUnchanged
+				break
+			}
+			item.Action = importActionUpdated
+			// 延续已有规则的版本号，使旧的If-Match及同步水位失效
+			rule.Version = old.Version + 1
+			expected[rule.ID] = old.Version
+			writes = append(writes, rule)
+		}
+		plan.Rules = append(plan.Rules, item)
+		plan.Summary[item.Action]++
+	}
+
+	if mode == ImportModeReplaceAll {
+		sort.Slice(scoped, func(i, j int) bool { return scoped[i].ID < scoped[j].ID })
+		for _, rule := range scoped {
+			if _, exists := seen[rule.ID]; exists {
+				continue
+			}
+			plan.Rules = append(plan.Rules, &types.ImportPlanItemDTO{ID: rule.ID, Path: rule.Path, Method: rule.Method, Action: importActionDeleted})
+			plan.Summary[importActionDeleted]++
+			deleted = append(deleted, rule.ID)
+			expected[rule.ID] = rule.Version
+		}
+	}
+	if dryRun {
+		return plan, nil
+	}
+
+	// 写入与删除在同一个事务中完成，避免只生效一部分
+	if len(writes) > 0 || len(deleted) > 0 {
+		if err := srv.rule.Replace(ctx, writes, deleted, expected); err != nil {
+			misc.Logger.Error("failed to import rules", zap.Error(err))
+			return nil, err
+		}
+	}
+	misc.Logger.Info("import rules", zap.String("namespace", namespace), zap.String("mode", mode), zap.Int("written", len(writes)), zap.Int("deleted", len(deleted)))
+
+	rids := make([]string, len(writes))
+	for index, rule := range writes {
+		rids[index] = rule.ID
+	}
+	if err := srv.publish(ctx, domain.RevisionActionImport, rids...); err != nil {
+		return nil, err
+	}
+	return plan, srv.publishDeletion(ctx, deleted...)
+}
@@ -0,0 +1,152 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+)
+
+// racingRuleRepository 在Replace之前执行beforeReplace，模拟导入快照之后的并发修改
+type racingRuleRepository struct {
+	domain.RuleRepository
+	beforeReplace func()
+}
+
+func (rr *racingRuleRepository) Replace(ctx context.Context, rules []*domain.Rule, deleted []string, expected map[string]int) error {
+	if rr.beforeReplace != nil {
+		rr.beforeReplace()
+		rr.beforeReplace = nil
+	}
+	return rr.RuleRepository.Replace(ctx, rules, deleted, expected)
+}
+
+func newImportedRule(path, body string) *types.RuleDTO {
+	return &types.RuleDTO{
+		Path:        path,
+		Method:      "GET",
+		Regulations: []*types.RegulationDTO{{IsDefault: true, Template: &types.TemplateDTO{Body: body}}},
+	}
+}
+
+func importActions(plan *types.ImportPlanDTO) map[string]string {
+	actions := map[string]string{}
+	for _, item := range plan.Rules {
+		actions[item.Path] = item.Action
+	}
+	return actions
+}
+
+func TestMockApplication_ImportMode(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	_, err := srv.Import(context.TODO(), "merge", false, newImportedRule("/a", "a"))
+	assert.Error(t, err)
+	_, err = srv.Import(context.TODO(), "", false, newImportedRule("/a", "a"), newImportedRule("/a", "b"))
+	assert.Error(t, err)
+
+	plan, err := srv.Import(context.TODO(), "", false, newImportedRule("/a", "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, ImportModeUpsert, plan.Mode)
+	assert.Equal(t, domain.DefaultNamespace, plan.Namespace)
+	assert.Equal(t, map[string]int{importActionCreated: 1}, plan.Summary)
+}
+
+func TestMockApplication_Import(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	ctx := ContextWithNamespace(context.TODO(), "payment")
+	plan, err := srv.Import(ctx, ImportModeUpsert, false, newImportedRule("/a", "a"), newImportedRule("/b", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{importActionCreated: 2}, plan.Summary)
+	rid := domain.GenRuleID("payment", "/a", "GET")
+	rule, err := srv.rule.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, "payment", rule.Namespace)
+	assert.Len(t, srv.listExecutors(ctx, "payment"), 2)
+
+	// create_only跳过已存在的规则
+	plan, err = srv.Import(ctx, ImportModeCreateOnly, false, newImportedRule("/a", "changed"), newImportedRule("/c", "c"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": importActionSkipped, "/c": importActionCreated}, importActions(plan))
+	rule, err = srv.rule.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", rule.Regulations[0].Template.Body)
+
+	// 内容相同的规则不写入，修改的规则延续版本号
+	version := rule.Version
+	plan, err = srv.Import(ctx, ImportModeUpsert, false, newImportedRule("/a", "changed"), newImportedRule("/b", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": importActionUpdated, "/b": importActionUnchanged}, importActions(plan))
+	if assert.Len(t, plan.Rules[0].Changes, 1) {
+		assert.Equal(t, "responses[0].response.body", plan.Rules[0].Changes[0].Field)
+	}
+	rule, err = srv.rule.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, version+1, rule.Version)
+	assert.Equal(t, "changed", rule.Regulations[0].Template.Body)
+
+	// dry_run只返回计划
+	plan, err = srv.Import(ctx, ImportModeReplaceAll, true, newImportedRule("/a", "dry"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{importActionUpdated: 1, importActionDeleted: 2}, plan.Summary)
+	rules, err := srv.rule.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+	rule, err = srv.rule.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", rule.Regulations[0].Template.Body)
+
+	// replace_all只删除当前命名空间中未导入的规则
+	_, err = srv.Import(context.TODO(), ImportModeUpsert, false, newImportedRule("/a", "default"))
+	assert.NoError(t, err)
+	plan, err = srv.Import(ctx, ImportModeReplaceAll, false, newImportedRule("/a", "changed"), newImportedRule("/d", "d"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": importActionUnchanged, "/b": importActionDeleted, "/c": importActionDeleted, "/d": importActionCreated}, importActions(plan))
+	rules, err = srv.rule.Export(ctx)
+	assert.NoError(t, err)
+	paths := map[string]string{}
+	for _, rule := range rules {
+		paths[rule.Namespace+rule.Path] = rule.ID
+	}
+	assert.Len(t, paths, 3)
+	assert.Contains(t, paths, "payment/a")
+	assert.Contains(t, paths, "payment/d")
+	assert.Contains(t, paths, domain.DefaultNamespace+"/a")
+	assert.Len(t, srv.listExecutors(ctx, "payment"), 2)
+}
+
+func TestMockApplication_ImportConflict(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	racing := &racingRuleRepository{RuleRepository: srv.rule}
+	srv.rule = racing
+	ctx := context.TODO()
+	_, err := srv.Import(ctx, ImportModeUpsert, false, newImportedRule("/a", "a"), newImportedRule("/b", "b"))
+	assert.NoError(t, err)
+	rid := domain.GenRuleID(domain.DefaultNamespace, "/a", "GET")
+
+	// 快照之后规则被修改，覆盖时版本号冲突且不写入任何规则
+	priority := 1
+	racing.beforeReplace = func() {
+		assert.NoError(t, srv.PatchRule(ctx, &types.RuleDTO{ID: rid, Priority: &priority}))
+	}
+	_, err = srv.Import(ctx, ImportModeReplaceAll, false, newImportedRule("/a", "changed"))
+	assert.True(t, errors.Is(err, domain.ErrVersionConflict))
+	rule, err := srv.rule.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", rule.Regulations[0].Template.Body)
+	assert.Equal(t, 1, rule.Priority)
+	rules, err := srv.rule.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	// 快照之后删除的规则同样冲突
+	racing.beforeReplace = func() {
+		assert.NoError(t, srv.DeleteRule(ctx, domain.GenRuleID(domain.DefaultNamespace, "/b", "GET")))
+	}
+	_, err = srv.Import(ctx, ImportModeReplaceAll, false, newImportedRule("/c", "c"))
+	assert.True(t, errors.Is(err, domain.ErrVersionConflict))
+	_, err = srv.rule.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+)
+
+// WithJournal 载入请求日志存储库，mock请求将被记录以供查询
+func (srv *mockApplication) WithJournal(journal domain.JournalRepository) {
+	srv.journal = journal
+}
+
+func (srv *mockApplication) writeJournal(ctx *fasthttp.RequestCtx, entry *domain.JournalEntry) {
+	if srv.journal == nil {
+		return
+	}
+	entry.Complete(&ctx.Response)
+	srv.journal.Append(context.TODO(), entry)
+}
+
+func convertJournalQueryDTO(namespace string, query *types.JournalQueryDTO) (domain.JournalFilter, error) {
+	filter := domain.JournalFilter{
+		Namespace: namespace,
+		RuleID:    query.RuleID,
+		Method:    query.Method,
+		Limit:     query.Limit,
+
+		Unmatched: query.Unmatched,
+	}
+	if query.Path != "" {
+		re, err := regexp.Compile(query.Path)
+		if err != nil {
+			return filter, err
+		}
+		filter.Path = re
+	}
+	if query.Since != nil {
+		filter.Since = *query.Since
+	}
+	if query.Until != nil {
+		filter.Until = *query.Until
+	}
+	return filter, nil
+}
+
+func convertJournalEntry(entry *domain.JournalEntry) *types.JournalEntryDTO {
+	dto := &types.JournalEntryDTO{
+		ID:              entry.ID,
+		Namespace:       entry.Namespace,
+		Method:          entry.Method,
+		Path:            entry.Path,
+		Query:           entry.Query,
+		Header:          entry.Header,
+		RuleID:          entry.RuleID,
+		RegulationIndex: entry.RegulationIndex,
+		Delay:           float64(entry.Delay.Microseconds()) / 1000,
+		Proxied:         entry.Proxied,
+		StatusCode:      entry.StatusCode,
+		ReceivedAt:      entry.ReceivedAt,
+		Duration:        float64(entry.Duration.Microseconds()) / 1000,
+		Diagnosis:       convertDiagnosis(entry.Diagnosis),
+	}
+	if entry.Fault != domain.FaultNone {
+		dto.Fault = entry.Fault
+	}
+	if utf8.Valid(entry.Body) {
+		dto.Body = string(entry.Body)
+	} else {
+		dto.B64EncodeBody = base64.StdEncoding.EncodeToString(entry.Body)
+	}
+	return dto
+}
+
+// ListRequests 查询上下文中命名空间的请求日志的user case
+func (srv *mockApplication) ListRequests(ctx context.Context, query *types.JournalQueryDTO) ([]*types.JournalEntryDTO, error) {
+	filter, err := convertJournalQueryDTO(namespaceFromContext(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*types.JournalEntryDTO, 0)
+	if srv.journal == nil {
+		return entries, nil
+	}
+	for _, entry := range srv.journal.List(ctx, filter) {
+		entries = append(entries, convertJournalEntry(entry))
+	}
+	return entries, nil
+}
+
+// ClearRequests 清空上下文中命名空间的请求日志的user case
+func (srv *mockApplication) ClearRequests(ctx context.Context) {
+	if srv.journal != nil {
+		srv.journal.Clear(ctx, domain.JournalFilter{Namespace: namespaceFromContext(ctx)})
+	}
+}
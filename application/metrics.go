@@ -0,0 +1,31 @@
+package application
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wosai/deepmock/misc"
+)
+
+var (
+	ruleHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "deepmock_rule_hits_total",
+		Help: "Number of mock requests matched by each rule.",
+	}, []string{"namespace", "rule_id"})
+	regulationHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "deepmock_regulation_hits_total",
+		Help: "Number of mock requests answered by each regulation.",
+	}, []string{"namespace", "rule_id", "regulation"})
+	unmatchedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "deepmock_unmatched_requests_total",
+		Help: "Number of mock requests that matched no rule.",
+	}, []string{"namespace", "proxied"})
+	renderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "deepmock_render_errors_total",
+		Help: "Number of failed response renderings.",
+	}, []string{"namespace", "rule_id"})
+	renderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "deepmock_render_duration_seconds",
+		Help:    "Time spent rendering mock responses.",
+		Buckets: misc.DefaultLatencyBuckets,
+	}, []string{"namespace", "rule_id"})
+)
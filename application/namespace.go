@@ -0,0 +1,59 @@
+package application
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+)
+
+// HeaderNamespace 指定命名空间的请求头，优先于按Host或路径前缀选择的命名空间
+const HeaderNamespace = "X-Deepmock-Namespace"
+
+type (
+	// NamespaceResolver 按Host或路径前缀选择mock请求命名空间的接口定义，按路径前缀选择时需去掉请求路径中的前缀
+	NamespaceResolver interface {
+		Resolve(*fasthttp.RequestCtx) string
+	}
+
+	namespaceKey struct{}
+)
+
+// WithNamespaceResolver 载入命名空间选择器，未载入时只能通过请求头选择命名空间
+func (srv *mockApplication) WithNamespaceResolver(resolver NamespaceResolver) {
+	srv.namespaces = resolver
+}
+
+// ContextWithNamespace 返回携带命名空间的上下文，管理接口的导出、导入、请求日志等user case只作用于该命名空间
+func ContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+func namespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceKey{}).(string)
+	return domain.NormalizeNamespace(namespace)
+}
+
+// resolveNamespace 选择mock请求的命名空间，即使请求头指定了命名空间，匹配的路径前缀也会被去掉
+func (srv *mockApplication) resolveNamespace(ctx *fasthttp.RequestCtx) string {
+	var namespace string
+	if srv.namespaces != nil {
+		namespace = srv.namespaces.Resolve(ctx)
+	}
+	if header := ctx.Request.Header.Peek(HeaderNamespace); len(header) > 0 {
+		namespace = string(header)
+	}
+	return domain.NormalizeNamespace(namespace)
+}
+
+// listExecutors 按匹配顺序返回命名空间中的执行器
+func (srv *mockApplication) listExecutors(ctx context.Context, namespace string) []*domain.Executor {
+	all := srv.executor.ListExecutors(ctx)
+	executors := make([]*domain.Executor, 0, len(all))
+	for _, executor := range all {
+		if executor.Namespace == namespace {
+			executors = append(executors, executor)
+		}
+	}
+	return executors
+}
@@ -0,0 +1,149 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrProxyDisabled 未配置上游地址时无法录制
+	ErrProxyDisabled = errors.New("upstream proxy is not configured")
+	// ErrRecordingActive 同一命名空间同一时间只允许一个录制会话
+	ErrRecordingActive = errors.New("another recording session is active")
+	// ErrRecordingNotFound 录制会话不存在
+	ErrRecordingNotFound = errors.New("recording session not found")
+)
+
+type (
+	recorder struct {
+		sessions []*domain.RecordingSession
+		mu       sync.RWMutex
+	}
+)
+
+// active 返回录制中且覆盖该命名空间及路径的会话
+func (rec *recorder) active(namespace string, path []byte) *domain.RecordingSession {
+	rec.mu.RLock()
+	defer rec.mu.RUnlock()
+
+	for _, session := range rec.sessions {
+		if session.Active() && session.Covers(namespace, path) {
+			return session
+		}
+	}
+	return nil
+}
+
+func convertRecordingSession(session *domain.RecordingSession) *types.RecordingDTO {
+	dto := &types.RecordingDTO{
+		ID:         session.ID,
+		Namespace:  session.Namespace,
+		PathPrefix: session.PathPrefix,
+		Active:     session.Active(),
+		StartedAt:  session.StartedAt,
+		Rules:      session.RuleIDs(),
+	}
+	if stopped := session.StoppedAt(); !stopped.IsZero() {
+		dto.StoppedAt = &stopped
+	}
+	return dto
+}
+
+// StartRecording 开始录制的user case，只录制上下文中命名空间的请求，每个命名空间同一时间只允许一个录制会话
+func (srv *mockApplication) StartRecording(ctx context.Context, prefix string) (*types.RecordingDTO, error) {
+	if srv.proxy == nil {
+		return nil, ErrProxyDisabled
+	}
+	if prefix == "" {
+		prefix = "/"
+	}
+
+	namespace := namespaceFromContext(ctx)
+	srv.recorder.mu.Lock()
+	defer srv.recorder.mu.Unlock()
+	for _, session := range srv.recorder.sessions {
+		if session.Namespace == namespace && session.Active() {
+			return nil, ErrRecordingActive
+		}
+	}
+
+	session := domain.NewRecordingSession(namespace, prefix)
+	srv.recorder.sessions = append(srv.recorder.sessions, session)
+	misc.Logger.Info("start recording session", zap.String("session_id", session.ID), zap.String("namespace", session.Namespace), zap.String("path_prefix", prefix))
+	return convertRecordingSession(session), nil
+}
+
+// StopRecording 停止录制的user case，未指定会话ID时停止上下文中命名空间当前录制中的会话；其他命名空间的会话视为不存在
+func (srv *mockApplication) StopRecording(ctx context.Context, sid string) (*types.RecordingDTO, error) {
+	namespace := namespaceFromContext(ctx)
+	srv.recorder.mu.Lock()
+	defer srv.recorder.mu.Unlock()
+
+	for _, session := range srv.recorder.sessions {
+		if session.Namespace != namespace {
+			continue
+		}
+		if session.ID == sid || (sid == "" && session.Active()) {
+			session.Stop()
+			misc.Logger.Info("stop recording session", zap.String("session_id", session.ID))
+			return convertRecordingSession(session), nil
+		}
+	}
+	return nil, ErrRecordingNotFound
+}
+
+// ListRecordings 查询上下文中命名空间的录制会话的user case
+func (srv *mockApplication) ListRecordings(ctx context.Context) []*types.RecordingDTO {
+	namespace := namespaceFromContext(ctx)
+	srv.recorder.mu.RLock()
+	defer srv.recorder.mu.RUnlock()
+
+	sessions := make([]*types.RecordingDTO, 0, len(srv.recorder.sessions))
+	for _, session := range srv.recorder.sessions {
+		if session.Namespace == namespace {
+			sessions = append(sessions, convertRecordingSession(session))
+		}
+	}
+	return sessions
+}
+
+// record 将转发的请求录制成规则并保存
+func (srv *mockApplication) record(ctx *fasthttp.RequestCtx, session *domain.RecordingSession) {
+	rule := session.Record(domain.NewRecordedExchange(&ctx.Request, &ctx.Response))
+
+	c, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.saveRecordedRule(c, rule); err != nil {
+		misc.Logger.Error("failed to save recorded rule", zap.String("session_id", session.ID), zap.String("rule_id", rule.ID), zap.Error(err))
+		return
+	}
+	misc.Logger.Info("recorded rule from upstream", zap.String("session_id", session.ID), zap.String("rule_id", rule.ID))
+}
+
+func (srv *mockApplication) saveRecordedRule(ctx context.Context, rule *domain.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	current, err := srv.rule.GetRuleByID(ctx, rule.ID)
+	switch {
+	case err == nil:
+		if err = current.Put(rule); err == nil {
+			err = srv.rule.UpdateRule(ctx, current)
+		}
+	case errors.Is(err, domain.ErrRuleNotExist):
+		err = srv.rule.CreateRule(ctx, rule)
+	}
+	if err != nil {
+		return err
+	}
+	return srv.publish(ctx, domain.RevisionActionRecord, rule.ID)
+}
@@ -0,0 +1,72 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+)
+
+// stubProxy 不转发任何请求，只用于开启录制
+type stubProxy struct{}
+
+func (stubProxy) Forward(context.Context, *fasthttp.RequestCtx) (bool, error) {
+	return false, nil
+}
+
+func TestMockApplication_RecordingNamespace(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	srv.WithProxy(stubProxy{})
+	payment := ContextWithNamespace(context.TODO(), "payment")
+	refund := ContextWithNamespace(context.TODO(), "refund")
+
+	// 每个命名空间同一时间只允许一个录制会话
+	paying, err := srv.StartRecording(payment, "/pay")
+	assert.NoError(t, err)
+	_, err = srv.StartRecording(payment, "/pay")
+	assert.Equal(t, ErrRecordingActive, err)
+	refunding, err := srv.StartRecording(refund, "")
+	assert.NoError(t, err)
+
+	if sessions := srv.ListRecordings(payment); assert.Len(t, sessions, 1) {
+		assert.Equal(t, paying.ID, sessions[0].ID)
+	}
+	assert.Empty(t, srv.ListRecordings(context.TODO()))
+
+	// 其他命名空间的会话视为不存在
+	_, err = srv.StopRecording(payment, refunding.ID)
+	assert.Equal(t, ErrRecordingNotFound, err)
+	_, err = srv.StopRecording(context.TODO(), "")
+	assert.Equal(t, ErrRecordingNotFound, err)
+
+	stopped, err := srv.StopRecording(payment, "")
+	assert.NoError(t, err)
+	assert.Equal(t, paying.ID, stopped.ID)
+	assert.False(t, stopped.Active)
+	if sessions := srv.ListRecordings(refund); assert.Len(t, sessions, 1) {
+		assert.True(t, sessions[0].Active)
+	}
+}
+
+func TestMockApplication_SaveRecordedRule(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	ctx := context.TODO()
+	rule := newTestRule("/a")
+
+	// 存储不可用时不创建规则
+	repo := srv.rule
+	srv.rule = &unavailableRuleRepository{RuleRepository: repo, err: context.DeadlineExceeded}
+	assert.Equal(t, context.DeadlineExceeded, srv.saveRecordedRule(ctx, rule))
+	_, err := repo.GetRuleByID(ctx, rule.ID)
+	assert.True(t, errors.Is(err, domain.ErrRuleNotExist))
+
+	srv.rule = repo
+	assert.NoError(t, srv.saveRecordedRule(ctx, rule))
+	assert.NoError(t, srv.saveRecordedRule(ctx, newTestRule("/a")))
+	current, err := repo.GetRuleByID(ctx, rule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, rule.Version+1, current.Version)
+}
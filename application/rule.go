@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -11,9 +13,14 @@ import (
 	"github.com/wosai/deepmock/domain"
 	"github.com/wosai/deepmock/misc"
 	"github.com/wosai/deepmock/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// maxScenarioAttempts 场景状态被并发修改时重新匹配的最大次数
+const maxScenarioAttempts = 5
+
 var (
 	// MockApplication 全局的mockApplication对象
 	MockApplication *mockApplication
@@ -31,37 +38,64 @@ type (
 		WithExecutorRepository(domain.ExecutorRepository)
 	}
 
+	// Proxy 未命中规则时的请求转发接口定义
+	Proxy interface {
+		Forward(context.Context, *fasthttp.RequestCtx) (bool, error) // 上下文中的链路信息写入发往上游的请求；转发失败时已写回网关错误响应
+	}
+
 	mockApplication struct {
-		rule     domain.RuleRepository
-		executor domain.ExecutorRepository
-		job      AsyncJob
-		counter  uint64
+		rule       domain.RuleRepository
+		executor   domain.ExecutorRepository
+		scenario   domain.ScenarioRepository
+		job        AsyncJob
+		proxy      Proxy
+		replicas   Replicas
+		namespaces NamespaceResolver
+		history    domain.RuleHistoryRepository
+		journal    domain.JournalRepository
+		recorder   recorder
+		diagnose   bool
+		counter    uint64
+		syncing    *syncRun // 正在执行的同步任务
+		syncMu     sync.Mutex
 	}
+
+	ifMatchKey struct{}
 )
 
 // BuildMockApplication mockApplication的工厂函数
-func BuildMockApplication(rr domain.RuleRepository, er domain.ExecutorRepository, job AsyncJob) *mockApplication {
-	MockApplication = &mockApplication{rule: rr, executor: er, job: job}
+func BuildMockApplication(rr domain.RuleRepository, er domain.ExecutorRepository, sr domain.ScenarioRepository, job AsyncJob) *mockApplication {
+	srv := &mockApplication{rule: rr, executor: er, scenario: sr, job: job}
+	MockApplication = srv
+	job.WithRuleRepository(rr)
+	job.WithExecutorRepository(er)
 	go func() {
-		job.WithRuleRepository(rr)
-		job.WithExecutorRepository(er)
 		t := time.NewTicker(job.Period())
 		for range t.C {
+			<-srv.startSync().done
 			misc.Logger.Info("async job complete")
-			if err := job.Do(); err != nil {
-				misc.Logger.Error("occur error on job", zap.Error(err))
-			}
 		}
 	}()
 	return MockApplication
 }
 
+// WithProxy 载入请求转发器，未命中规则的请求将转发至上游服务
+func (srv *mockApplication) WithProxy(proxy Proxy) {
+	srv.proxy = proxy
+}
+
 func convertRuleDTO(rule *types.RuleDTO) *domain.Rule {
 	r := &domain.Rule{
-		ID:       rule.ID,
-		Path:     rule.Path,
-		Method:   rule.Method,
-		Variable: rule.Variable,
+		ID:        rule.ID,
+		Namespace: rule.Namespace,
+		Path:      rule.Path,
+		Method:    rule.Method,
+		Variable:  rule.Variable,
+		Scenario:  convertScenarioDTO(rule.Scenario),
+		Disabled:  rule.Disabled,
+	}
+	if rule.Priority != nil {
+		r.Priority = *rule.Priority
 	}
 	if rule.Weight != nil {
 		r.Weight = make(map[string]domain.WeightFactor)
@@ -79,14 +113,8 @@ func convertRuleDTO(rule *types.RuleDTO) *domain.Rule {
 }
 
 func convertRegulationDTO(reg *types.RegulationDTO) *domain.Regulation {
-	r := &domain.Regulation{IsDefault: reg.IsDefault}
-	if reg.Filter != nil {
-		r.Filter = &domain.Filter{
-			Query:  reg.Filter.Query,
-			Header: reg.Filter.Header,
-			Body:   reg.Filter.Body,
-		}
-	}
+	r := &domain.Regulation{IsDefault: reg.IsDefault, Fault: reg.Fault, Scenario: convertScenarioDTO(reg.Scenario)}
+	r.Filter = convertFilterDTO(reg.Filter)
 	if reg.Template != nil {
 		r.Template = &domain.Template{
 			IsTemplate:     reg.Template.IsTemplate,
@@ -96,6 +124,7 @@ func convertRegulationDTO(reg *types.RegulationDTO) *domain.Regulation {
 			Body:           reg.Template.Body,
 			B64EncodedBody: reg.Template.B64EncodeBody,
 			StatusCode:     reg.Template.StatusCode, // 默认不传，设置为200
+			Delay:          convertDelayDTO(reg.Template.Delay),
 		}
 		if reg.Template.StatusCode == 0 {
 			r.Template.StatusCode = http.StatusOK
@@ -104,12 +133,63 @@ func convertRegulationDTO(reg *types.RegulationDTO) *domain.Regulation {
 	return r
 }
 
+func convertFilterDTO(f *types.FilterDTO) *domain.Filter {
+	if f == nil {
+		return nil
+	}
+	r := &domain.Filter{
+		Query:  f.Query,
+		Header: f.Header,
+		Body:   f.Body,
+		Not:    convertFilterDTO(f.Not),
+	}
+	for _, sub := range f.AllOf {
+		r.AllOf = append(r.AllOf, convertFilterDTO(sub))
+	}
+	for _, sub := range f.AnyOf {
+		r.AnyOf = append(r.AnyOf, convertFilterDTO(sub))
+	}
+	return r
+}
+
+func convertDelayDTO(d *types.DelayDTO) *domain.Delay {
+	if d == nil {
+		return nil
+	}
+	return &domain.Delay{
+		Distribution: d.Distribution,
+		Fixed:        d.Fixed,
+		Min:          d.Min,
+		Max:          d.Max,
+		Mean:         d.Mean,
+		Stddev:       d.Stddev,
+		Median:       d.Median,
+		Sigma:        d.Sigma,
+		Template:     d.Template,
+	}
+}
+
+func convertScenarioDTO(s *types.ScenarioDTO) *domain.Scenario {
+	if s == nil {
+		return nil
+	}
+	return &domain.Scenario{Name: s.Name, RequiredState: s.RequiredState, NewState: s.NewState}
+}
+
 func convertRuleEntity(rule *domain.Rule) *types.RuleDTO {
 	r := &types.RuleDTO{
-		ID:       rule.ID,
-		Path:     rule.Path,
-		Method:   rule.Method,
-		Variable: rule.Variable,
+		ID:        rule.ID,
+		Namespace: rule.Namespace,
+		Path:      rule.Path,
+		Method:    rule.Method,
+		Variable:  rule.Variable,
+		Scenario:  convertScenarioVO(rule.Scenario),
+		Disabled:  rule.Disabled,
+		Version:   rule.Version,
+	}
+	if rule.Priority != 0 {
+		priority := rule.Priority
+		r.Priority = &priority
 	}
 	if rule.Weight != nil {
 		r.Weight = make(types.WeightDTO)
@@ -128,6 +208,8 @@ func convertRuleEntity(rule *domain.Rule) *types.RuleDTO {
 func convertRegulationVO(reg *domain.Regulation) *types.RegulationDTO {
 	r := &types.RegulationDTO{
 		IsDefault: reg.IsDefault,
+		Fault:     reg.Fault,
+		Scenario:  convertScenarioVO(reg.Scenario),
 		Template: &types.TemplateDTO{
 			IsTemplate:     reg.Template.IsTemplate,
 			RenderHeader:   reg.Template.RenderHeader,
@@ -136,22 +218,108 @@ func convertRegulationVO(reg *domain.Regulation) *types.RegulationDTO {
 			StatusCode:     reg.Template.StatusCode,
 			Body:           reg.Template.Body,
 			B64EncodeBody:  reg.Template.B64EncodedBody,
+			Delay:          convertDelayVO(reg.Template.Delay),
 		},
 	}
 
-	if reg.Filter != nil {
-		r.Filter = &types.FilterDTO{
-			Header: reg.Filter.Header,
-			Query:  reg.Filter.Query,
-			Body:   reg.Filter.Body,
-		}
+	r.Filter = convertFilterVO(reg.Filter)
+	return r
+}
+
+func convertFilterVO(f *domain.Filter) *types.FilterDTO {
+	if f == nil {
+		return nil
+	}
+	r := &types.FilterDTO{
+		Header: f.Header,
+		Query:  f.Query,
+		Body:   f.Body,
+		Not:    convertFilterVO(f.Not),
+	}
+	for _, sub := range f.AllOf {
+		r.AllOf = append(r.AllOf, convertFilterVO(sub))
+	}
+	for _, sub := range f.AnyOf {
+		r.AnyOf = append(r.AnyOf, convertFilterVO(sub))
 	}
 	return r
 }
 
-// CreateRule 创建规则的user case
+func convertDelayVO(d *domain.Delay) *types.DelayDTO {
+	if d == nil {
+		return nil
+	}
+	return &types.DelayDTO{
+		Distribution: d.Distribution,
+		Fixed:        d.Fixed,
+		Min:          d.Min,
+		Max:          d.Max,
+		Mean:         d.Mean,
+		Stddev:       d.Stddev,
+		Median:       d.Median,
+		Sigma:        d.Sigma,
+		Template:     d.Template,
+	}
+}
+
+func convertScenarioVO(s *domain.Scenario) *types.ScenarioDTO {
+	if s == nil {
+		return nil
+	}
+	return &types.ScenarioDTO{Name: s.Name, RequiredState: s.RequiredState, NewState: s.NewState}
+}
+
+// RuleConverter 规则报文与规则实体之间的转换，供以types.RuleDTO格式保存规则的存储库使用
+var RuleConverter ruleConverter
+
+type ruleConverter struct{}
+
+// ToEntity 转换成规则实体
+func (ruleConverter) ToEntity(rule *types.RuleDTO) *domain.Rule {
+	return convertRuleDTO(rule)
+}
+
+// ToDTO 转换成规则报文
+func (ruleConverter) ToDTO(rule *domain.Rule) *types.RuleDTO {
+	return convertRuleEntity(rule)
+}
+
+// ContextWithIfMatch 返回携带期望版本号的上下文，修改规则时版本号不一致将返回*domain.VersionConflictError
+func ContextWithIfMatch(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, version)
+}
+
+// checkIfMatch 校验规则的当前版本号是否与上下文中的期望版本号一致，未指定期望版本号时不校验
+func checkIfMatch(ctx context.Context, rule *domain.Rule) error {
+	if expected, ok := ctx.Value(ifMatchKey{}).(int); ok && expected != rule.Version {
+		return &domain.VersionConflictError{RuleID: rule.ID, Expected: expected}
+	}
+	return nil
+}
+
+// getRule 获取上下文命名空间中的规则，属于其他命名空间的规则视为不存在
+func (srv *mockApplication) getRule(ctx context.Context, rid string) (*domain.Rule, error) {
+	rule, err := srv.rule.GetRuleByID(ctx, rid)
+	if err != nil {
+		return nil, err
+	}
+	if !inNamespace(ctx, rule) {
+		return nil, &domain.RuleNotExistError{RuleID: rid}
+	}
+	return rule, nil
+}
+
+// inNamespace 规则是否属于上下文中的命名空间
+func inNamespace(ctx context.Context, rule *domain.Rule) bool {
+	return domain.NormalizeNamespace(rule.Namespace) == namespaceFromContext(ctx)
+}
+
+// CreateRule 创建规则的user case，规则未指定命名空间时属于上下文中的命名空间
 func (srv *mockApplication) CreateRule(ctx context.Context, rule *types.RuleDTO) (string, error) {
 	ru := convertRuleDTO(rule)
+	if ru.Namespace == "" {
+		ru.Namespace = namespaceFromContext(ctx)
+	}
 	rid, _ := ru.SupplyID()
 	if err := ru.Validate(); err != nil {
 		misc.Logger.Error("failed to validate rule content", zap.Error(err))
@@ -163,12 +331,12 @@ func (srv *mockApplication) CreateRule(ctx context.Context, rule *types.RuleDTO)
 		return rid, err
 	}
 	misc.Logger.Info("created new rule record with id", zap.String("rule_id", ru.ID))
-	return rid, nil
+	return rid, srv.publish(ctx, domain.RevisionActionCreate, rid)
 }
 
 // GetRule 获取规则的user case
 func (srv *mockApplication) GetRule(ctx context.Context, rid string) (*types.RuleDTO, error) {
-	re, err := srv.rule.GetRuleByID(ctx, rid)
+	re, err := srv.getRule(ctx, rid)
 	if err != nil {
 		misc.Logger.Error("failed to find rule record", zap.String("rule_id", rid), zap.Error(err))
 		return nil, err
@@ -181,23 +349,34 @@ func (srv *mockApplication) GetRule(ctx context.Context, rid string) (*types.Rul
 	return rule, nil
 }
 
-// DeleteRule 删除规则的user case
+// DeleteRule 删除规则的user case，指定了期望版本号时由存储库在删除的同时校验
 func (srv *mockApplication) DeleteRule(ctx context.Context, rid string) error {
-	if err := srv.rule.DeleteRule(ctx, rid); err != nil {
+	// 删除不存在的规则仍然交由存储库处理，只拒绝其他命名空间中的规则
+	if rule, err := srv.rule.GetRuleByID(ctx, rid); err == nil && !inNamespace(ctx, rule) {
+		return &domain.RuleNotExistError{RuleID: rid}
+	}
+	version, ok := ctx.Value(ifMatchKey{}).(int)
+	if !ok {
+		version = domain.AnyVersion
+	}
+	if err := srv.rule.DeleteRule(ctx, rid, version); err != nil {
 		misc.Logger.Error("failed to delete rule entity", zap.String("rule_id", rid), zap.Error(err))
 		return err
 	}
-	return nil
+	return srv.publishDeletion(ctx, rid)
 }
 
 // PutRule 全量更新规则的user case
 func (srv *mockApplication) PutRule(ctx context.Context, rule *types.RuleDTO) error {
-	or, err := srv.rule.GetRuleByID(ctx, rule.ID)
+	or, err := srv.getRule(ctx, rule.ID)
 	if err != nil {
 		misc.Logger.Error("cannot found rule record with id", zap.String("rule_id", rule.ID), zap.Error(err))
 		return err
 	}
 
+	if err := checkIfMatch(ctx, or); err != nil {
+		return err
+	}
 	nr := convertRuleDTO(rule)
 	if err := or.Put(nr); err != nil {
 		misc.Logger.Error("failed to validate rule after put", zap.String("rule_id", rule.ID), zap.Error(err))
@@ -208,19 +387,22 @@ func (srv *mockApplication) PutRule(ctx context.Context, rule *types.RuleDTO) er
 		return err
 	}
 	misc.Logger.Info("update the rule record with id", zap.String("rule_id", rule.ID))
-	return nil
+	return srv.publish(ctx, domain.RevisionActionUpdate, rule.ID)
 }
 
 // PatchRule 部分更新规则的user case
 func (srv *mockApplication) PatchRule(ctx context.Context, rule *types.RuleDTO) error {
-	or, err := srv.rule.GetRuleByID(ctx, rule.ID)
+	or, err := srv.getRule(ctx, rule.ID)
 	if err != nil {
 		misc.Logger.Error("cannot found rule record with id", zap.String("rule_id", rule.ID), zap.Error(err))
 		return err
 	}
 
+	if err := checkIfMatch(ctx, or); err != nil {
+		return err
+	}
 	nr := convertRuleDTO(rule)
-	if err := or.Patch(nr); err != nil {
+	if err := or.Patch(nr, rule.Priority); err != nil {
 		misc.Logger.Error("failed to validate rule after patch", zap.String("rule_id", rule.ID), zap.Error(err))
 		return err
 	}
@@ -229,57 +411,225 @@ func (srv *mockApplication) PatchRule(ctx context.Context, rule *types.RuleDTO)
 		return err
 	}
 	misc.Logger.Info("patch the rule record with id", zap.String("rule_id", rule.ID))
-	return nil
+	return srv.publish(ctx, domain.RevisionActionUpdate, rule.ID)
 }
 
-// Export 导出的user case
-func (srv *mockApplication) Export(ctx context.Context) ([]*types.RuleDTO, error) {
+// Export 导出上下文中命名空间的规则的user case，includeDisabled为false时不导出禁用的规则
+func (srv *mockApplication) Export(ctx context.Context, includeDisabled bool) ([]*types.RuleDTO, error) {
 	res, err := srv.rule.Export(ctx)
 	if err != nil {
 		misc.Logger.Error("failed to export rules", zap.Error(err))
 		return nil, err
 	}
-	rules := make([]*types.RuleDTO, len(res))
-	for index, re := range res {
+	namespace := namespaceFromContext(ctx)
+	rules := make([]*types.RuleDTO, 0, len(res))
+	for _, re := range res {
+		if re.Namespace != namespace || (re.Disabled && !includeDisabled) {
+			continue
+		}
 		if err := re.Validate(); err != nil {
 			misc.Logger.Error("failed to convert as resource", zap.String("rule_id", re.ID), zap.Error(err))
 			return nil, err
 		}
-		r := convertRuleEntity(re)
-		rules[index] = r
+		rules = append(rules, convertRuleEntity(re))
 	}
 	return rules, nil
 }
 
-// Import 导入规则的user case
-func (srv *mockApplication) Import(ctx context.Context, rules ...*types.RuleDTO) error {
-	res := make([]*domain.Rule, len(rules))
-	for index, rule := range rules {
-		ru := convertRuleDTO(rule)
-		if err := ru.Validate(); err != nil {
-			misc.Logger.Error("failed to validate rule content", zap.String("rule_id", rule.ID), zap.Error(err))
-			return err
-		}
+// DisableRule 禁用规则的user case，禁用的规则不参与匹配，请求将回落到其他规则
+func (srv *mockApplication) DisableRule(ctx context.Context, rid string) error {
+	return srv.setRuleDisabled(ctx, rid, true)
+}
 
-		res[index] = ru
-	}
+// EnableRule 启用规则的user case
+func (srv *mockApplication) EnableRule(ctx context.Context, rid string) error {
+	return srv.setRuleDisabled(ctx, rid, false)
+}
 
-	if err := srv.rule.Import(ctx, res...); err != nil {
-		misc.Logger.Error("failed to import rules", zap.Error(err))
+func (srv *mockApplication) setRuleDisabled(ctx context.Context, rid string, disabled bool) error {
+	rule, err := srv.getRule(ctx, rid)
+	if err != nil {
+		misc.Logger.Error("cannot found rule record with id", zap.String("rule_id", rid), zap.Error(err))
 		return err
 	}
-	return nil
+	if err := checkIfMatch(ctx, rule); err != nil {
+		return err
+	}
+	if rule.Disabled == disabled {
+		return nil
+	}
+	rule.SetDisabled(disabled)
+	if err := srv.rule.UpdateRule(ctx, rule); err != nil {
+		misc.Logger.Error("failed to update rule record", zap.String("rule_id", rid), zap.Error(err))
+		return err
+	}
+	action := domain.RevisionActionEnable
+	if disabled {
+		action = domain.RevisionActionDisable
+	}
+	misc.Logger.Info(string(action)+" the rule record with id", zap.String("rule_id", rid))
+	return srv.publish(ctx, action, rid)
 }
 
 // MockAPI Mock接口的user case
-func (srv *mockApplication) MockAPI(ctx *fasthttp.RequestCtx) error {
+func (srv *mockApplication) MockAPI(ctx *fasthttp.RequestCtx) (err error) {
 	index := atomic.AddUint64(&srv.counter, 1)
-	misc.Logger.Info("received request", zap.Uint64("index", index), zap.ByteString("path", ctx.Request.URI().Path()), zap.ByteString("method", ctx.Request.Header.Method()))
-	exec, founded := srv.executor.FindExecutor(context.TODO(), ctx.Request.URI().Path(), ctx.Request.Header.Method())
+	// 请求日志记录客户端发送的原始请求，解析命名空间时可能会去掉路径前缀
+	entry := domain.NewJournalEntry(index, "", ctx)
+	defer srv.writeJournal(ctx, entry)
+	namespace := srv.resolveNamespace(ctx)
+	entry.Namespace = namespace
+	misc.Logger.Info("received request", zap.Uint64("index", index), zap.String("namespace", namespace), zap.ByteString("path", ctx.Request.URI().Path()), zap.ByteString("method", ctx.Request.Header.Method()))
+
+	c, span := misc.Tracer().Start(misc.ExtractTraceContext(context.Background(), &ctx.Request.Header), "mock "+entry.Method, trace.WithSpanKind(trace.SpanKindServer))
+	defer func() { finishMockSpan(span, ctx, entry, err) }()
+
+	if session := srv.recorder.active(namespace, ctx.Request.URI().Path()); session != nil && srv.proxy != nil {
+		forwarded, err := srv.proxy.Forward(c, ctx)
+		if forwarded && err == nil {
+			srv.record(ctx, session)
+		}
+		if forwarded {
+			// 上游失败时已写回网关错误响应，不作为客户端的错误请求
+			entry.Proxied = true
+			return nil
+		}
+	}
+
+	exec, regulation, founded := srv.matchExecutor(ctx, namespace)
 	if !founded {
+		if srv.proxy != nil {
+			if forwarded, _ := srv.proxy.Forward(c, ctx); forwarded {
+				entry.Proxied = true
+				unmatchedRequests.WithLabelValues(namespace, "true").Inc()
+				return nil
+			}
+		}
 		misc.Logger.Warn("no matched rule founded", zap.Uint64("index", index))
+		unmatchedRequests.WithLabelValues(namespace, "false").Inc()
+		if srv.diagnosing(ctx) {
+			diagnosis := domain.DiagnoseUnmatched(context.TODO(), srv.listExecutors(context.TODO(), namespace), &ctx.Request, srv.scenario)
+			entry.Diagnosis = diagnosis
+			ctx.Response.Header.Set(HeaderDiagnosis, diagnosis.Summary())
+			return &UnmatchedError{Diagnosis: convertDiagnosis(diagnosis)}
+		}
 		return ErrRuleNotFound
 	}
 	misc.Logger.Info("found matched rule", zap.Uint64("index", index), zap.String("rule_id", exec.ID))
-	return exec.FindRegulationExecutor(&ctx.Request).Render(ctx, exec.Variable, exec.Weight.DiceAll())
+	params := exec.PathParams(ctx.Request.URI().Path())
+	weight := exec.Weight.DiceAll()
+	entry.RuleID, entry.RegulationIndex = exec.ID, exec.RegulationIndex(regulation)
+	ruleHits.WithLabelValues(namespace, exec.ID).Inc()
+	regulationHits.WithLabelValues(namespace, exec.ID, strconv.Itoa(entry.RegulationIndex)).Inc()
+	delay := regulation.Delay(ctx, exec.Variable, weight, params)
+	fault := regulation.PickFault()
+	entry.Delay, entry.Fault = delay, fault
+
+	switch fault {
+	case domain.FaultNone, domain.FaultTruncatedBody:
+		start := time.Now()
+		err := regulation.Render(ctx, exec.Variable, weight, params)
+		renderDuration.WithLabelValues(namespace, exec.ID).Observe(time.Since(start).Seconds())
+		if err != nil {
+			renderErrors.WithLabelValues(namespace, exec.ID).Inc()
+			return err
+		}
+	}
+	if srv.diagnosing(ctx) {
+		if diagnosis := exec.DiagnoseFallback(context.TODO(), &ctx.Request, srv.scenario, regulation); diagnosis != nil {
+			entry.Diagnosis = diagnosis
+			ctx.Response.Header.Set(HeaderDiagnosis, diagnosis.Summary())
+		}
+	}
+	waitUntil(ctx, delay)
+
+	if fault != domain.FaultNone {
+		misc.Logger.Info("inject fault into response", zap.Uint64("index", index), zap.String("fault", fault))
+		domain.InjectFault(ctx, fault)
+	}
+	return nil
+}
+
+// finishMockSpan 记录mock请求的匹配结果并结束Span
+func finishMockSpan(span trace.Span, ctx *fasthttp.RequestCtx, entry *domain.JournalEntry, err error) {
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("http.method", entry.Method),
+		attribute.String("http.target", entry.Path),
+		attribute.Int("http.status_code", ctx.Response.StatusCode()),
+		attribute.String("deepmock.namespace", entry.Namespace),
+	)
+	if entry.RuleID != "" {
+		span.SetAttributes(
+			attribute.String("deepmock.rule_id", entry.RuleID),
+			attribute.Int("deepmock.regulation_index", entry.RegulationIndex),
+			attribute.Float64("deepmock.delay_ms", float64(entry.Delay.Microseconds())/1000),
+		)
+	}
+	if entry.Fault != "" && entry.Fault != domain.FaultNone {
+		span.SetAttributes(attribute.String("deepmock.fault", entry.Fault))
+	}
+	if entry.Proxied {
+		span.SetAttributes(attribute.Bool("deepmock.proxied", true))
+	}
+	misc.FinishSpan(span, err)
+}
+
+// matchExecutor 查找命中的执行器及报文规则，并在响应前原子地切换场景状态；场景状态被并发的请求修改时重新匹配
+func (srv *mockApplication) matchExecutor(ctx *fasthttp.RequestCtx, namespace string) (*domain.Executor, *domain.RegulationExecutor, bool) {
+	path, method := ctx.Request.URI().Path(), ctx.Request.Header.Method()
+	for attempt := 0; attempt < maxScenarioAttempts; attempt++ {
+		exec, founded := srv.executor.FindExecutor(context.TODO(), namespace, path, method)
+		if founded && !exec.Available(context.TODO(), srv.scenario) {
+			exec, founded = srv.findAvailableExecutor(namespace, path, method)
+		}
+		if !founded {
+			return nil, nil, false
+		}
+		regulation := exec.FindRegulationExecutor(context.TODO(), &ctx.Request, srv.scenario)
+		if exec.Transit(context.TODO(), srv.scenario, regulation) {
+			return exec, regulation, true
+		}
+		misc.Logger.Info("scenario state changed concurrently, match again", zap.String("rule_id", exec.ID))
+	}
+	return nil, nil, false
+}
+
+// findAvailableExecutor 在命名空间中按匹配顺序查找场景状态满足要求的执行器
+func (srv *mockApplication) findAvailableExecutor(namespace string, path, method []byte) (*domain.Executor, bool) {
+	for _, exec := range srv.listExecutors(context.TODO(), namespace) {
+		if exec.Match(path, method) && exec.Available(context.TODO(), srv.scenario) {
+			return exec, true
+		}
+	}
+	return nil, false
+}
+
+// ListScenarios 查询上下文中命名空间的场景状态的user case
+func (srv *mockApplication) ListScenarios(ctx context.Context) map[string]string {
+	return srv.scenario.ListStates(ctx, namespaceFromContext(ctx))
+}
+
+// ResetScenarios 重置上下文中命名空间的场景状态的user case
+func (srv *mockApplication) ResetScenarios(ctx context.Context, names ...string) {
+	namespace := namespaceFromContext(ctx)
+	srv.scenario.Reset(ctx, namespace, names...)
+	misc.Logger.Info("reset scenario states", zap.String("namespace", namespace), zap.Strings("names", names))
+}
+
+// waitUntil 等待至请求接收后经过delay时长，渲染耗时计入延迟，服务关闭时立即返回
+func waitUntil(ctx *fasthttp.RequestCtx, delay time.Duration) {
+	remaining := delay - time.Since(ctx.Time())
+	if remaining <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
 }
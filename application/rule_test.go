@@ -0,0 +1,110 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/infrastructure"
+	"github.com/wosai/deepmock/option"
+	"github.com/wosai/deepmock/types"
+)
+
+func TestMockApplication_DeleteRuleIfMatch(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	ctx := context.TODO()
+	rule := newTestRule("/a")
+	rule.Version = 1
+	assert.NoError(t, srv.rule.CreateRule(ctx, rule))
+	assert.NoError(t, srv.publish(ctx, domain.RevisionActionCreate, rule.ID))
+
+	err := srv.DeleteRule(ContextWithIfMatch(ctx, 2), rule.ID)
+	assert.True(t, errors.Is(err, domain.ErrVersionConflict))
+	assert.Len(t, srv.executor.ListExecutors(ctx), 1)
+
+	assert.NoError(t, srv.DeleteRule(ContextWithIfMatch(ctx, 1), rule.ID))
+	assert.Empty(t, srv.executor.ListExecutors(ctx))
+	assert.True(t, errors.Is(srv.DeleteRule(ContextWithIfMatch(ctx, 1), rule.ID), domain.ErrVersionConflict))
+	assert.NoError(t, srv.DeleteRule(ctx, rule.ID))
+}
+
+func TestMockApplication_RuleNamespace(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	srv.WithHistory(infrastructure.NewMemoryRuleHistoryRepository())
+	payment := ContextWithNamespace(context.TODO(), "payment")
+	rid, err := srv.CreateRule(payment, &types.RuleDTO{
+		Path:        "/a",
+		Method:      "GET",
+		Regulations: []*types.RegulationDTO{{IsDefault: true, Template: &types.TemplateDTO{Body: "a"}}},
+	})
+	assert.NoError(t, err)
+
+	// 其他命名空间中按ID操作规则都视为规则不存在
+	other := context.TODO()
+	_, err = srv.GetRule(other, rid)
+	assert.Error(t, err)
+	assert.Error(t, srv.PutRule(other, &types.RuleDTO{ID: rid, Path: "/a", Method: "GET"}))
+	assert.Error(t, srv.PatchRule(other, &types.RuleDTO{ID: rid}))
+	assert.Error(t, srv.DisableRule(other, rid))
+	assert.Error(t, srv.DeleteRule(other, rid))
+	_, err = srv.ListRevisions(other, rid)
+	assert.Error(t, err)
+	_, err = srv.GetRevision(other, rid, 1)
+	assert.Error(t, err)
+	_, err = srv.DiffRevisions(other, rid, 1, 1)
+	assert.Error(t, err)
+	assert.Error(t, srv.Rollback(other, &types.RollbackDTO{RuleID: rid, Revision: 1}))
+
+	rule, err := srv.GetRule(payment, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", rule.Regulations[0].Template.Body)
+	assert.False(t, rule.Disabled)
+	revisions, err := srv.ListRevisions(payment, rid)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+	assert.NoError(t, srv.DeleteRule(payment, rid))
+}
+
+func TestMockApplication_ScenarioNamespace(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	payment := ContextWithNamespace(context.TODO(), "payment")
+	refund := ContextWithNamespace(context.TODO(), "refund")
+	assert.True(t, srv.scenario.CompareAndSet(payment, "payment", domain.ScenarioTransition{Name: "order", State: "paid"}))
+	assert.True(t, srv.scenario.CompareAndSet(refund, "refund", domain.ScenarioTransition{Name: "order", State: "refunded"}))
+
+	assert.Equal(t, map[string]string{"order": "paid"}, srv.ListScenarios(payment))
+	assert.Empty(t, srv.ListScenarios(context.TODO()))
+
+	srv.ResetScenarios(payment)
+	assert.Empty(t, srv.ListScenarios(payment))
+	assert.Equal(t, map[string]string{"order": "refunded"}, srv.ListScenarios(refund))
+}
+
+func TestMockApplication_MockAPIJournalPath(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	resolver, err := infrastructure.NewNamespaceResolver(option.NamespaceOption{Prefixes: []string{"/payment=payment"}})
+	assert.NoError(t, err)
+	srv.WithNamespaceResolver(resolver)
+	srv.WithJournal(infrastructure.NewJournalRepository(16))
+	payment := ContextWithNamespace(context.TODO(), "payment")
+	_, err = srv.CreateRule(payment, &types.RuleDTO{
+		Path:        "/v1/pay",
+		Method:      "GET",
+		Regulations: []*types.RegulationDTO{{IsDefault: true, Template: &types.TemplateDTO{Body: "paid"}}},
+	})
+	assert.NoError(t, err)
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetRequestURI("/payment/v1/pay")
+	assert.NoError(t, srv.MockAPI(ctx))
+	assert.Equal(t, "paid", string(ctx.Response.Body()))
+
+	// 请求日志及校验使用客户端发送的原始路径
+	res, err := srv.Verify(payment, &types.VerifyDTO{RequestPatternDTO: types.RequestPatternDTO{Method: "GET", Path: "/payment/v1/pay"}})
+	assert.NoError(t, err)
+	assert.True(t, res.Passed)
+	assert.Equal(t, 1, res.Count)
+}
@@ -0,0 +1,193 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+	"go.uber.org/zap"
+)
+
+type (
+	// Replicas 多实例部署时其他实例的接口定义，用于等待所有实例完成规则同步
+	Replicas interface {
+		WaitForSync(context.Context, *types.SyncWaitDTO) error
+	}
+
+	syncTimeoutKey struct{}
+
+	// syncRun 一次正在执行的同步任务，并发的等待者共享其结果
+	syncRun struct {
+		done chan struct{}
+		err  error
+	}
+)
+
+const (
+	// syncPollInterval 等待同步时检查执行器的间隔
+	syncPollInterval = 100 * time.Millisecond
+	// maxSyncTimeout 客户端指定的等待同步时长的上限
+	maxSyncTimeout = time.Minute
+)
+
+var (
+	// ErrSyncTimeout 等待规则同步超时，规则已经保存
+	ErrSyncTimeout = errors.New("rule is saved but timed out waiting for sync")
+)
+
+// WithReplicas 载入其他实例，修改规则时可以等待所有实例完成同步
+func (srv *mockApplication) WithReplicas(replicas Replicas) {
+	srv.replicas = replicas
+}
+
+// ContextWithSyncTimeout 返回携带等待同步时长的上下文，修改规则的user case将等待所有实例完成同步后返回；时长最多为1分钟
+func ContextWithSyncTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, syncTimeoutKey{}, capSyncTimeout(timeout))
+}
+
+func capSyncTimeout(timeout time.Duration) time.Duration {
+	if timeout > maxSyncTimeout {
+		return maxSyncTimeout
+	}
+	return timeout
+}
+
+func syncTimeoutFromContext(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(syncTimeoutKey{}).(time.Duration)
+	return timeout
+}
+
+// publish 发布已保存的规则：记录历史版本，并立即载入本实例的执行器以保证读己之写，禁用的规则从执行器中移除；其他实例依赖同步任务
+func (srv *mockApplication) publish(ctx context.Context, action domain.RevisionAction, rids ...string) error {
+	executors := make([]*domain.Executor, 0, len(rids))
+	targets := make([]*types.SyncTargetDTO, 0, len(rids))
+	var deleted []string
+	for _, rid := range rids {
+		// 重新读取以获得存储库维护的版本号及修改时间，与同步任务的结果保持一致
+		rule, err := srv.rule.GetRuleByID(ctx, rid)
+		if err != nil {
+			misc.Logger.Warn("failed to reload saved rule, leave it to sync job", zap.String("rule_id", rid), zap.Error(err))
+			continue
+		}
+		srv.saveRevision(ctx, action, rid, rule)
+		if rule.Disabled {
+			deleted = append(deleted, rid)
+			targets = append(targets, &types.SyncTargetDTO{ID: rid, Deleted: true})
+			continue
+		}
+		executor, err := rule.To()
+		if err != nil {
+			misc.Logger.Warn("failed to convert saved rule, leave it to sync job", zap.String("rule_id", rid), zap.Error(err))
+			continue
+		}
+		executors = append(executors, executor)
+		targets = append(targets, &types.SyncTargetDTO{ID: rid, Version: rule.Version, MTime: rule.MTime})
+	}
+	srv.executor.Apply(ctx, executors, deleted)
+	return srv.waitForReplicas(ctx, targets)
+}
+
+// publishDeletion 记录删除操作，并立即从本实例的执行器中移除已删除的规则
+func (srv *mockApplication) publishDeletion(ctx context.Context, rids ...string) error {
+	if len(rids) == 0 {
+		return nil
+	}
+	targets := make([]*types.SyncTargetDTO, len(rids))
+	for index, rid := range rids {
+		srv.saveRevision(ctx, domain.RevisionActionDelete, rid, nil)
+		targets[index] = &types.SyncTargetDTO{ID: rid, Deleted: true}
+	}
+	srv.executor.Apply(ctx, nil, rids)
+	return srv.waitForReplicas(ctx, targets)
+}
+
+func (srv *mockApplication) waitForReplicas(ctx context.Context, targets []*types.SyncTargetDTO) error {
+	timeout := syncTimeoutFromContext(ctx)
+	if timeout <= 0 || srv.replicas == nil || len(targets) == 0 {
+		return nil
+	}
+	if err := srv.replicas.WaitForSync(ctx, &types.SyncWaitDTO{Rules: targets, Timeout: timeout.String()}); err != nil {
+		misc.Logger.Error("failed to wait for replicas", zap.Error(err))
+		return errors.New(ErrSyncTimeout.Error() + ": " + err.Error())
+	}
+	return nil
+}
+
+// WaitForSync 等待本实例的执行器同步至指定的规则版本，等待期间主动执行同步任务，时长最多为1分钟
+func (srv *mockApplication) WaitForSync(ctx context.Context, wait *types.SyncWaitDTO) error {
+	timeout, err := time.ParseDuration(wait.Timeout)
+	if err != nil {
+		return err
+	}
+	deadline := time.NewTimer(capSyncTimeout(timeout))
+	defer deadline.Stop()
+
+	for !srv.synced(wait.Rules) {
+		select {
+		case <-srv.startSync().done:
+		case <-deadline.C:
+			return ErrSyncTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if srv.synced(wait.Rules) {
+			return nil
+		}
+
+		select {
+		case <-time.After(syncPollInterval):
+		case <-deadline.C:
+			return ErrSyncTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// startSync 执行同步任务；已有同步任务在执行时直接返回该任务，避免定时任务与并发的等待请求重复同步
+func (srv *mockApplication) startSync() *syncRun {
+	srv.syncMu.Lock()
+	defer srv.syncMu.Unlock()
+	if srv.syncing != nil {
+		return srv.syncing
+	}
+
+	run := &syncRun{done: make(chan struct{})}
+	srv.syncing = run
+	go func() {
+		run.err = srv.job.Do()
+		if run.err != nil {
+			misc.Logger.Error("occur error on job", zap.Error(run.err))
+		}
+		srv.syncMu.Lock()
+		srv.syncing = nil
+		srv.syncMu.Unlock()
+		close(run.done)
+	}()
+	return run
+}
+
+// synced 执行器是否已经包含指定的规则版本，修改时间相同时比较版本号
+func (srv *mockApplication) synced(targets []*types.SyncTargetDTO) bool {
+	current := map[string]*domain.Executor{}
+	for _, executor := range srv.executor.ListExecutors(context.Background()) {
+		current[executor.ID] = executor
+	}
+	for _, target := range targets {
+		executor, exists := current[target.ID]
+		if target.Deleted {
+			if exists {
+				return false
+			}
+			continue
+		}
+		if !exists || executor.MTime.Before(target.MTime) || (executor.MTime.Equal(target.MTime) && executor.Version < target.Version) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/infrastructure"
+	"github.com/wosai/deepmock/types"
+)
+
+// countingJob 记录同步任务的执行次数，每次执行耗时delay
+type countingJob struct {
+	*infrastructure.Job
+	delay time.Duration
+	calls int32
+}
+
+func (job *countingJob) Do() error {
+	atomic.AddInt32(&job.calls, 1)
+	time.Sleep(job.delay)
+	return job.Job.Do()
+}
+
+// newTestApplication 基于内存存储库构建应用，不启动定时同步
+func newTestApplication(delay time.Duration) (*mockApplication, *countingJob) {
+	job := &countingJob{Job: infrastructure.NewJob(time.Hour, 0), delay: delay}
+	srv := &mockApplication{
+		rule:     infrastructure.NewMemoryRuleRepository(),
+		executor: infrastructure.NewExecutorRepository(16),
+		scenario: infrastructure.NewScenarioRepository(),
+		history:  infrastructure.NewMemoryRuleHistoryRepository(),
+		job:      job,
+	}
+	job.WithRuleRepository(srv.rule)
+	job.WithExecutorRepository(srv.executor)
+	return srv, job
+}
+
+func newTestRule(path string) *domain.Rule {
+	rule := &domain.Rule{
+		Path:        path,
+		Method:      "GET",
+		Regulations: []*domain.Regulation{{IsDefault: true, Template: &domain.Template{Body: path}}},
+	}
+	rule.SupplyID()
+	return rule
+}
+
+func syncTarget(t *testing.T, srv *mockApplication, rid string) *types.SyncTargetDTO {
+	rule, err := srv.rule.GetRuleByID(context.TODO(), rid)
+	assert.NoError(t, err)
+	return &types.SyncTargetDTO{ID: rid, Version: rule.Version, MTime: rule.MTime}
+}
+
+func TestMockApplication_Publish(t *testing.T) {
+	srv, job := newTestApplication(0)
+	ctx := context.TODO()
+
+	rule := newTestRule("/a")
+	assert.NoError(t, srv.rule.CreateRule(ctx, rule))
+	target := syncTarget(t, srv, rule.ID)
+	assert.False(t, srv.synced([]*types.SyncTargetDTO{target}))
+
+	// 发布后本实例立即生效，不依赖同步任务
+	assert.NoError(t, srv.publish(ctx, domain.RevisionActionCreate, rule.ID, "missing"))
+	assert.True(t, srv.synced([]*types.SyncTargetDTO{target}))
+	assert.Equal(t, int32(0), job.calls)
+	revisions, err := srv.history.ListRevisions(ctx, rule.ID)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+
+	// 禁用的规则从执行器中移除
+	rule, err = srv.rule.GetRuleByID(ctx, rule.ID)
+	assert.NoError(t, err)
+	rule.SetDisabled(true)
+	assert.NoError(t, srv.rule.UpdateRule(ctx, rule))
+	assert.NoError(t, srv.publish(ctx, domain.RevisionActionUpdate, rule.ID))
+	assert.Empty(t, srv.executor.ListExecutors(ctx))
+	assert.True(t, srv.synced([]*types.SyncTargetDTO{{ID: rule.ID, Deleted: true}}))
+
+	assert.NoError(t, srv.publishDeletion(ctx))
+	assert.NoError(t, srv.publishDeletion(ctx, rule.ID))
+	revisions, err = srv.history.ListRevisions(ctx, rule.ID)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+}
+
+func TestMockApplication_Synced(t *testing.T) {
+	srv, _ := newTestApplication(0)
+	ctx := context.TODO()
+	rule := newTestRule("/a")
+	assert.NoError(t, srv.rule.CreateRule(ctx, rule))
+	assert.NoError(t, srv.publish(ctx, domain.RevisionActionCreate, rule.ID))
+	current := syncTarget(t, srv, rule.ID)
+
+	assert.True(t, srv.synced(nil))
+	assert.True(t, srv.synced([]*types.SyncTargetDTO{current}))
+	assert.True(t, srv.synced([]*types.SyncTargetDTO{{ID: rule.ID, Version: current.Version - 1, MTime: current.MTime}}))
+	assert.True(t, srv.synced([]*types.SyncTargetDTO{{ID: rule.ID, Version: current.Version + 1, MTime: current.MTime.Add(-time.Second)}}))
+	assert.False(t, srv.synced([]*types.SyncTargetDTO{{ID: rule.ID, Version: current.Version + 1, MTime: current.MTime}}))
+	assert.False(t, srv.synced([]*types.SyncTargetDTO{{ID: rule.ID, Version: current.Version, MTime: current.MTime.Add(time.Second)}}))
+	assert.False(t, srv.synced([]*types.SyncTargetDTO{{ID: rule.ID, Deleted: true}}))
+	assert.False(t, srv.synced([]*types.SyncTargetDTO{current, {ID: "missing"}}))
+	assert.True(t, srv.synced([]*types.SyncTargetDTO{current, {ID: "missing", Deleted: true}}))
+}
+
+func TestMockApplication_WaitForSync(t *testing.T) {
+	srv, job := newTestApplication(50 * time.Millisecond)
+	ctx := context.TODO()
+
+	// 规则由其他实例保存，只能通过同步任务生效
+	rule := newTestRule("/a")
+	assert.NoError(t, srv.rule.CreateRule(ctx, rule))
+	wait := &types.SyncWaitDTO{Rules: []*types.SyncTargetDTO{syncTarget(t, srv, rule.ID)}, Timeout: "5s"}
+
+	// 并发的等待者共享同一次同步
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = srv.WaitForSync(ctx, wait)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	calls := atomic.LoadInt32(&job.calls)
+	assert.True(t, calls >= 1 && calls <= 2, calls)
+
+	// 已经同步时不再执行同步任务
+	assert.NoError(t, srv.WaitForSync(ctx, wait))
+	assert.Equal(t, calls, atomic.LoadInt32(&job.calls))
+
+	wait = &types.SyncWaitDTO{Rules: []*types.SyncTargetDTO{{ID: "missing"}}, Timeout: "200ms"}
+	assert.Equal(t, ErrSyncTimeout, srv.WaitForSync(ctx, wait))
+	wait.Timeout = "bad"
+	assert.Error(t, srv.WaitForSync(ctx, wait))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	wait.Timeout = "5s"
+	assert.Equal(t, context.Canceled, srv.WaitForSync(cancelled, wait))
+}
+
+func TestCapSyncTimeout(t *testing.T) {
+	assert.Equal(t, 5*time.Second, capSyncTimeout(5*time.Second))
+	assert.Equal(t, maxSyncTimeout, capSyncTimeout(time.Hour))
+	assert.Equal(t, maxSyncTimeout, syncTimeoutFromContext(ContextWithSyncTimeout(context.TODO(), 24*time.Hour)))
+}
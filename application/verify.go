@@ -0,0 +1,82 @@
+package application
+
+import (
+	"context"
+	"sort"
+
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+	"go.uber.org/zap"
+)
+
+// maxNearMisses 校验失败时最多返回的相近请求数量
+const maxNearMisses = 3
+
+func convertRequestPatternDTO(pattern *types.RequestPatternDTO) *domain.RequestPattern {
+	rp := &domain.RequestPattern{Method: pattern.Method, Path: pattern.Path}
+	if pattern.Query != nil || pattern.Header != nil || pattern.Body != nil ||
+		pattern.AllOf != nil || pattern.AnyOf != nil || pattern.Not != nil {
+		rp.Filter = convertFilterDTO(&pattern.FilterDTO)
+	}
+	return rp
+}
+
+func convertCountDTO(count *types.CountDTO) domain.CountConstraint {
+	if count == nil {
+		return domain.CountConstraint{}
+	}
+	return domain.CountConstraint{Exactly: count.Exactly, AtLeast: count.AtLeast, AtMost: count.AtMost}
+}
+
+// Verify 根据上下文中命名空间的请求日志校验请求次数的user case
+func (srv *mockApplication) Verify(ctx context.Context, verify *types.VerifyDTO) (*types.VerifyResultDTO, error) {
+	constraint := convertCountDTO(verify.Count)
+	if err := constraint.Validate(); err != nil {
+		return nil, err
+	}
+	matcher, err := convertRequestPatternDTO(&verify.RequestPatternDTO).To()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*domain.JournalEntry
+	if srv.journal != nil {
+		entries = srv.journal.List(ctx, domain.JournalFilter{Namespace: namespaceFromContext(ctx)})
+	}
+
+	type nearMiss struct {
+		entry   *domain.JournalEntry
+		reasons []string
+	}
+	var misses []nearMiss
+	res := &types.VerifyResultDTO{Expected: constraint.String()}
+	for _, entry := range entries {
+		reasons := matcher.Mismatches(entry)
+		if len(reasons) == 0 {
+			res.Count++
+			continue
+		}
+		misses = append(misses, nearMiss{entry: entry, reasons: reasons})
+	}
+	res.Passed = constraint.Satisfied(res.Count)
+	if res.Passed {
+		return res, nil
+	}
+
+	// 未满足条数越少越接近，相同时最近的请求优先
+	sort.SliceStable(misses, func(i, j int) bool {
+		if len(misses[i].reasons) != len(misses[j].reasons) {
+			return len(misses[i].reasons) < len(misses[j].reasons)
+		}
+		return misses[i].entry.ID > misses[j].entry.ID
+	})
+	for i := 0; i < len(misses) && i < maxNearMisses; i++ {
+		res.NearMisses = append(res.NearMisses, &types.NearMissDTO{
+			Request:    convertJournalEntry(misses[i].entry),
+			Mismatches: misses[i].reasons,
+		})
+	}
+	misc.Logger.Info("request verification failed", zap.String("expected", res.Expected), zap.Int("count", res.Count))
+	return res, nil
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/jacexh/requests"
 	"github.com/wosai/deepmock/types"
@@ -37,11 +38,23 @@ type (
 		Response
 		Data []*types.RuleDO `json:"data,omitempty"`
 	}
+
+	// VerifyResponse 请求校验接口返回报文
+	VerifyResponse struct {
+		Response
+		Data *types.VerifyResultDTO `json:"data,omitempty"`
+	}
+
+	// VerificationError 请求校验未通过时返回的错误
+	VerificationError struct {
+		Result *types.VerifyResultDTO
+	}
 )
 
 const (
-	entrypointRule  = "/api/v1/rule"
-	entrypointRules = "/api/v1/rules"
+	entrypointRule   = "/api/v1/rule"
+	entrypointRules  = "/api/v1/rules"
+	entrypointVerify = "/api/v1/verify"
 
 	returnCodeOK = 200
 )
@@ -59,6 +72,15 @@ func (e *DeepMockError) Error() string {
 	return fmt.Sprintf("[%d]: %s", e.code, e.err)
 }
 
+// Error error的实现
+func (e *VerificationError) Error() string {
+	msg := fmt.Sprintf("expected %s matched request(s), got %d", e.Result.Expected, e.Result.Count)
+	for _, miss := range e.Result.NearMisses {
+		msg += fmt.Sprintf("\n  near miss #%d %s %s: %s", miss.Request.ID, miss.Request.Method, miss.Request.Path, strings.Join(miss.Mismatches, "; "))
+	}
+	return msg
+}
+
 // NewDeepMockClient client的工厂函数
 func NewDeepMockClient(url string) *DeepMockClient {
 	session := requests.NewSession(requests.Option{Name: "DeepMock Go Client"})
@@ -158,3 +180,48 @@ func (c *DeepMockClient) ImportRules(rules ...*types.RuleDO) error {
 	}
 	return nil
 }
+
+// Verify 根据请求日志校验请求次数，返回校验结果
+func (c *DeepMockClient) Verify(verify *types.VerifyDTO) (*types.VerifyResultDTO, error) {
+	res := new(VerifyResponse)
+	_, _, err := c.client.Post(c.url+entrypointVerify, requests.Params{Json: verify}, requests.UnmarshalJSONResponse(res))
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != returnCodeOK {
+		return nil, NewDeepMockError(res.Response)
+	}
+	return res.Data, nil
+}
+
+// VerifyCount 校验满足匹配模式的请求次数，未通过时返回*VerificationError
+func (c *DeepMockClient) VerifyCount(pattern *types.RequestPatternDTO, count *types.CountDTO) error {
+	verify := &types.VerifyDTO{Count: count}
+	if pattern != nil {
+		verify.RequestPatternDTO = *pattern
+	}
+	result, err := c.Verify(verify)
+	if err != nil {
+		return err
+	}
+	if !result.Passed {
+		return &VerificationError{Result: result}
+	}
+	return nil
+}
+
+// VerifyCalled 校验满足匹配模式的请求至少出现过一次
+func (c *DeepMockClient) VerifyCalled(pattern *types.RequestPatternDTO) error {
+	return c.VerifyCount(pattern, nil)
+}
+
+// VerifyCalledTimes 校验满足匹配模式的请求恰好出现n次
+func (c *DeepMockClient) VerifyCalledTimes(pattern *types.RequestPatternDTO, n int) error {
+	return c.VerifyCount(pattern, &types.CountDTO{Exactly: &n})
+}
+
+// VerifyNotCalled 校验满足匹配模式的请求从未出现
+func (c *DeepMockClient) VerifyNotCalled(pattern *types.RequestPatternDTO) error {
+	zero := 0
+	return c.VerifyCount(pattern, &types.CountDTO{Exactly: &zero})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,6 +11,7 @@ import (
 	"github.com/jacexh/multiconfig"
 	"github.com/valyala/fasthttp"
 	"github.com/wosai/deepmock/application"
+	"github.com/wosai/deepmock/domain"
 	"github.com/wosai/deepmock/infrastructure"
 	"github.com/wosai/deepmock/misc"
 	"github.com/wosai/deepmock/option"
@@ -26,17 +28,59 @@ func main() {
 	opt := new(option.Option)
 	loader.MustLoad(opt)
 
-	// 连接数据库
-	db := infrastructure.BuildDBConnection(opt.DB)
+	// 初始化规则存储
+	rule, history, closeRule := buildRuleRepository(opt.Storage, opt.DB)
 	mem := infrastructure.NewExecutorRepository(1000)
-	job := infrastructure.NewJob(2 * time.Second)
+	job := infrastructure.NewJob(opt.Sync.Period, opt.Sync.FullPeriod)
+
+	// 链路追踪
+	exporter, err := infrastructure.NewSpanExporter(opt.Tracing)
+	if err != nil {
+		panic(err)
+	}
+	if exporter != nil {
+		misc.SetSpanExporter(exporter, opt.Tracing.ServiceName)
+		rule = infrastructure.NewTracedRuleRepository(rule, opt.Storage.Driver)
+		misc.Logger.Info("tracing is enabled", zap.String("exporter", opt.Tracing.Exporter))
+	}
 
 	// 初始化service
-	application.BuildMockApplication(
-		infrastructure.NewRuleRepository(db),
+	srv := application.BuildMockApplication(
+		rule,
 		mem,
+		infrastructure.NewScenarioRepository(),
 		job,
 	)
+	srv.WithHistory(history)
+	srv.WithJournal(infrastructure.NewJournalRepository(opt.Journal.Size))
+	srv.WithDiagnostics(opt.Diagnostics.Enabled)
+
+	proxy, err := infrastructure.NewReverseProxy(opt.Proxy)
+	if err != nil {
+		panic(err)
+	}
+	if proxy.Enabled() {
+		srv.WithProxy(proxy)
+		misc.Logger.Info("forward unmatched requests to upstream", zap.Any("proxy", opt.Proxy))
+	}
+
+	namespaces, err := infrastructure.NewNamespaceResolver(opt.Namespace)
+	if err != nil {
+		panic(err)
+	}
+	if namespaces.Enabled() {
+		srv.WithNamespaceResolver(namespaces)
+		misc.Logger.Info("resolve namespaces of mock requests", zap.Any("namespace", opt.Namespace))
+	}
+
+	replicas, err := infrastructure.NewReplicas(opt.Sync.Peers)
+	if err != nil {
+		panic(err)
+	}
+	if replicas.Enabled() {
+		srv.WithReplicas(replicas)
+		misc.Logger.Info("wait for replicas to sync rules on demand", zap.Strings("peers", opt.Sync.Peers))
+	}
 
 	// 初始化http handler
 	app := router.BuildRouter()
@@ -63,5 +107,61 @@ func main() {
 		errChan <- fmt.Errorf("caught signal: %s", (<-sigs).String())
 	}()
 
-	misc.Logger.Panic("deepmock is shutdown", zap.Error(<-errChan))
+	err = <-errChan
+	closeRule()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if e := misc.ShutdownTracing(ctx); e != nil {
+		misc.Logger.Error("failed to shutdown tracing", zap.Error(e))
+	}
+	misc.Logger.Panic("deepmock is shutdown", zap.Error(err))
+}
+
+// buildRuleRepository 根据配置创建规则存储库及历史版本存储库，同时返回关闭服务时需要执行的清理函数；
+// memory与file后端的历史版本只保存在内存中
+func buildRuleRepository(storage option.StorageOption, database option.DatabaseOption) (domain.RuleRepository, domain.RuleHistoryRepository, func()) {
+	switch storage.Driver {
+	case infrastructure.StorageDriverMySQL:
+		mysql := infrastructure.NewRuleRepository(infrastructure.BuildDBConnection(database))
+		return mysql, mysql, func() {}
+
+	case infrastructure.StorageDriverMemory:
+		memory := infrastructure.NewMemoryRuleRepository()
+		if storage.Snapshot == "" {
+			misc.Logger.Info("rules are stored in memory")
+			return memory, infrastructure.NewMemoryRuleHistoryRepository(), func() {}
+		}
+		if err := memory.LoadSnapshot(storage.Snapshot); err != nil {
+			panic(err)
+		}
+		misc.Logger.Info("rules are stored in memory", zap.String("snapshot", storage.Snapshot))
+		return memory, infrastructure.NewMemoryRuleHistoryRepository(), func() {
+			if err := memory.SaveSnapshot(storage.Snapshot); err != nil {
+				misc.Logger.Error("failed to save rule snapshot", zap.String("snapshot", storage.Snapshot), zap.Error(err))
+			}
+		}
+
+	case infrastructure.StorageDriverFile:
+		file, err := infrastructure.NewFileRuleRepository(storage.Dir, storage.ReadOnly, application.RuleConverter)
+		if err != nil {
+			panic(err)
+		}
+		misc.Logger.Info("rules are stored in files", zap.String("dir", storage.Dir), zap.Bool("read_only", storage.ReadOnly))
+		return file, infrastructure.NewMemoryRuleHistoryRepository(), func() {}
+
+	case infrastructure.StorageDriverBolt:
+		bolt, err := infrastructure.NewBoltRuleRepository(storage.Path)
+		if err != nil {
+			panic(err)
+		}
+		misc.Logger.Info("rules are stored in bolt database", zap.String("path", storage.Path))
+		return bolt, bolt, func() {
+			if err := bolt.Close(); err != nil {
+				misc.Logger.Error("failed to close bolt database", zap.String("path", storage.Path), zap.Error(err))
+			}
+		}
+
+	default:
+		panic("unsupported storage driver: " + storage.Driver)
+	}
 }
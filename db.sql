@@ -1,15 +1,36 @@
 CREATE TABLE `rule` (
   `id` varchar(36) NOT NULL COMMENT 'rule规则ID',
+  `namespace` varchar(64) NOT NULL DEFAULT 'default' COMMENT '规则所属的命名空间',
   `path` varchar(128) NOT NULL COMMENT 'Mock API监听路径，支持正则表达式',
   `method` varchar(16) NOT NULL COMMENT 'Mock API请求方式，GET/POST/PATCH/PUT/DELETE等',
   `variable` blob COMMENT '规则级别的变量',
   `weight` blob COMMENT '规则级别的权重字段',
   `responses` blob COMMENT '规则对应的response regulation',
+  `scenario` blob COMMENT '规则级别的场景设置',
+  `priority` int(8) NOT NULL DEFAULT '0' COMMENT '规则匹配优先级，值越大越优先匹配',
   `version` int(8) NOT NULL DEFAULT '0' COMMENT '规则版本号，每更新一次+1',
   `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '规则创建时间',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '规则修改时间',
   `disabled` tinyint(1) NOT NULL DEFAULT '0' COMMENT '规则是否启用',
   PRIMARY KEY (`id`),
   UNIQUE KEY `rule_id_uindex` (`id`),
-  UNIQUE KEY `rule_api_uindex` (`path`,`method`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
\ No newline at end of file
+  UNIQUE KEY `rule_api_uindex` (`namespace`,`path`,`method`),
+  KEY `rule_mtime_index` (`mtime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `rule_tombstone` (
+  `id` varchar(36) NOT NULL COMMENT '被删除的rule规则ID',
+  `dtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '规则删除时间',
+  PRIMARY KEY (`id`),
+  KEY `rule_tombstone_dtime_index` (`dtime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `rule_history` (
+  `rule_id` varchar(36) NOT NULL COMMENT 'rule规则ID',
+  `revision` int(8) NOT NULL COMMENT '历史版本号，按规则从1开始递增',
+  `action` varchar(16) NOT NULL COMMENT '产生该版本的操作，create/update/delete/import/record/rollback',
+  `author` varchar(128) NOT NULL DEFAULT '' COMMENT '修改人',
+  `content` blob COMMENT '修改后的规则，删除时为空',
+  `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`rule_id`,`revision`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
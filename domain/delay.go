@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/misc"
+	"go.uber.org/zap"
+)
+
+const (
+	// DelayFixed 固定延迟
+	DelayFixed DelayDistribution = "fixed"
+	// DelayUniform 在[min, max]区间内均匀分布的延迟
+	DelayUniform DelayDistribution = "uniform"
+	// DelayNormal 正态分布的延迟
+	DelayNormal DelayDistribution = "normal"
+	// DelayLogNormal 对数正态分布的延迟，更接近真实服务的长尾响应
+	DelayLogNormal DelayDistribution = "lognormal"
+
+	// MaxDelay 允许模拟的最大延迟
+	MaxDelay = time.Minute
+)
+
+type (
+	// DelayDistribution 延迟分布类型
+	DelayDistribution = string
+
+	// Delay 响应延迟值对象，单位均为毫秒
+	Delay struct {
+		Distribution DelayDistribution `json:"distribution,omitempty"`
+		Fixed        int               `json:"fixed,omitempty"`
+		Min          int               `json:"min,omitempty"`
+		Max          int               `json:"max,omitempty"`
+		Mean         float64           `json:"mean,omitempty"`
+		Stddev       float64           `json:"stddev,omitempty"`
+		Median       float64           `json:"median,omitempty"`
+		Sigma        float64           `json:"sigma,omitempty"`
+		Template     string            `json:"template,omitempty"` // 渲染结果为毫秒数，优先于分布生效
+	}
+
+	// DelayExecutor 响应延迟执行器
+	DelayExecutor struct {
+		delay    Delay
+		template *template.Template
+	}
+)
+
+// Validate 校验函数
+func (d *Delay) Validate() error {
+	if d == nil {
+		return nil
+	}
+	if d.Distribution == "" {
+		d.Distribution = DelayFixed
+	}
+
+	switch d.Distribution {
+	case DelayFixed:
+		if d.Fixed < 0 {
+			return errors.New("negative fixed delay")
+		}
+	case DelayUniform:
+		if d.Min < 0 || d.Max < d.Min {
+			return errors.New("bad uniform delay range")
+		}
+	case DelayNormal:
+		if d.Mean < 0 || d.Stddev < 0 {
+			return errors.New("bad normal delay params")
+		}
+	case DelayLogNormal:
+		if d.Median <= 0 || d.Sigma < 0 {
+			return errors.New("bad lognormal delay params")
+		}
+	default:
+		return errors.New("unsupported delay distribution: " + d.Distribution)
+	}
+	return nil
+}
+
+// To 转换成DelayExecutor
+func (d *Delay) To() (*DelayExecutor, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	de := &DelayExecutor{delay: *d}
+	if d.Template != "" {
+		tmpl, err := template.New(misc.GenRandomString(10)).Funcs(defaultTemplateFuncs).Parse(d.Template)
+		if err != nil {
+			return nil, err
+		}
+		de.template = tmpl
+	}
+	return de, nil
+}
+
+// Duration 计算本次请求需要模拟的延迟
+func (de *DelayExecutor) Duration(ctx *fasthttp.RequestCtx, v map[string]interface{}, weight map[string]string, params map[string]string) time.Duration {
+	if de == nil {
+		return 0
+	}
+
+	var ms float64
+	if de.template != nil {
+		rc := &RenderContext{Path: string(ctx.Request.URI().Path()), PathParams: params}
+		rc.parseParams(ctx, v, weight)
+		var buf bytes.Buffer
+		if err := de.template.Execute(&buf, rc); err != nil {
+			misc.Logger.Error("failed to render delay template", zap.Error(err))
+			return 0
+		}
+		val, err := strconv.ParseFloat(strings.TrimSpace(buf.String()), 64)
+		if err != nil {
+			misc.Logger.Error("bad delay template result", zap.String("result", buf.String()), zap.Error(err))
+			return 0
+		}
+		ms = val
+	} else {
+		ms = de.sample()
+	}
+
+	d := time.Duration(ms * float64(time.Millisecond))
+	switch {
+	case d < 0:
+		return 0
+	case d > MaxDelay:
+		return MaxDelay
+	default:
+		return d
+	}
+}
+
+func (de *DelayExecutor) sample() float64 {
+	switch de.delay.Distribution {
+	case DelayUniform:
+		return float64(de.delay.Min + rand.Intn(de.delay.Max-de.delay.Min+1))
+	case DelayNormal:
+		return rand.NormFloat64()*de.delay.Stddev + de.delay.Mean
+	case DelayLogNormal:
+		return de.delay.Median * math.Exp(rand.NormFloat64()*de.delay.Sigma)
+	default:
+		return float64(de.delay.Fixed)
+	}
+}
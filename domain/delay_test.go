@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestDelay_Validate(t *testing.T) {
+	var d *Delay
+	assert.NoError(t, d.Validate())
+
+	d = &Delay{Fixed: 100}
+	assert.NoError(t, d.Validate())
+	assert.Equal(t, DelayFixed, d.Distribution)
+
+	assert.Error(t, (&Delay{Distribution: DelayUniform, Min: 200, Max: 100}).Validate())
+	assert.Error(t, (&Delay{Distribution: DelayLogNormal}).Validate())
+	assert.Error(t, (&Delay{Distribution: "poisson"}).Validate())
+}
+
+func TestDelayExecutor_Duration(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders?slow=1")
+
+	de, err := (&Delay{Fixed: 150}).To()
+	assert.NoError(t, err)
+	assert.Equal(t, 150*time.Millisecond, de.Duration(ctx, nil, nil, nil))
+
+	de, err = (&Delay{Distribution: DelayUniform, Min: 10, Max: 20}).To()
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		d := de.Duration(ctx, nil, nil, nil)
+		assert.True(t, d >= 10*time.Millisecond && d <= 20*time.Millisecond)
+	}
+
+	de, err = (&Delay{Distribution: DelayNormal, Mean: 10, Stddev: 100}).To()
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.True(t, de.Duration(ctx, nil, nil, nil) >= 0)
+	}
+
+	de, err = (&Delay{Fixed: 10, Template: `{{if eq .Query.slow "1"}}3000{{else}}0{{end}}`}).To()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Second, de.Duration(ctx, nil, nil, nil))
+
+	de, err = (&Delay{Fixed: 10 * int(MaxDelay/time.Millisecond)}).To()
+	assert.NoError(t, err)
+	assert.Equal(t, MaxDelay, de.Duration(ctx, nil, nil, nil))
+
+	de = nil
+	assert.Equal(t, time.Duration(0), de.Duration(ctx, nil, nil, nil))
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// MaxDiagnosisCandidates 诊断结果中最多保留的候选规则数量
+const MaxDiagnosisCandidates = 3
+
+type (
+	// Diagnosis 未命中规则或者回落到默认regulation的请求诊断结果值对象
+	Diagnosis struct {
+		Fallback   bool   // 为true时表示命中了规则，但由默认regulation响应
+		RuleID     string // 回落时命中的规则ID
+		Candidates []*RuleMiss
+	}
+
+	// RuleMiss 候选规则及未命中的原因，按接近程度排序
+	RuleMiss struct {
+		RuleID      string
+		Method      string
+		Path        string
+		Reasons     []string
+		Regulations []*RegulationMiss
+
+		pathMissed   bool
+		commonPrefix int
+	}
+
+	// RegulationMiss 候选regulation及未命中的原因
+	RegulationMiss struct {
+		Index   int
+		Reasons []string
+	}
+)
+
+// DiagnoseUnmatched 请求未命中任何规则时，按接近程度列出候选规则及原因
+func DiagnoseUnmatched(ctx context.Context, executors []*Executor, request *fasthttp.Request, sr ScenarioRepository) *Diagnosis {
+	path, method := request.URI().Path(), request.Header.Method()
+	misses := make([]*RuleMiss, 0, len(executors))
+	for _, exec := range executors {
+		miss := &RuleMiss{RuleID: exec.ID, Method: string(exec.Method), Path: exec.Path.String()}
+		if string(method) != miss.Method {
+			miss.Reasons = append(miss.Reasons, fmt.Sprintf("method expected %q got %q", miss.Method, method))
+		}
+		if !exec.Path.Match(path) {
+			miss.pathMissed = true
+			miss.commonPrefix = commonPrefixLen(strings.TrimPrefix(miss.Path, "^"), string(path))
+			miss.Reasons = append(miss.Reasons, fmt.Sprintf("path %q does not match /%s/", path, miss.Path))
+		}
+		if reason := exec.Scenario.mismatch(ctx, sr, exec.Namespace); reason != "" {
+			miss.Reasons = append(miss.Reasons, reason)
+		}
+		if len(miss.Reasons) == 0 {
+			continue
+		}
+		if !miss.pathMissed && string(method) == miss.Method {
+			miss.Regulations = exec.diagnoseRegulations(ctx, request, sr)
+		}
+		misses = append(misses, miss)
+	}
+
+	sort.SliceStable(misses, func(i, j int) bool {
+		if misses[i].pathMissed != misses[j].pathMissed {
+			return !misses[i].pathMissed
+		}
+		if len(misses[i].Reasons) != len(misses[j].Reasons) {
+			return len(misses[i].Reasons) < len(misses[j].Reasons)
+		}
+		return misses[i].commonPrefix > misses[j].commonPrefix
+	})
+	if len(misses) > MaxDiagnosisCandidates {
+		misses = misses[:MaxDiagnosisCandidates]
+	}
+	return &Diagnosis{Candidates: misses}
+}
+
+// DiagnoseFallback 请求由默认regulation响应时，按接近程度列出未命中的regulation及原因；
+// 规则中没有其他regulation时不属于回落，返回nil
+func (exe *Executor) DiagnoseFallback(ctx context.Context, request *fasthttp.Request, sr ScenarioRepository, chosen *RegulationExecutor) *Diagnosis {
+	if chosen == nil || !chosen.IsDefault {
+		return nil
+	}
+	regulations := exe.diagnoseRegulations(ctx, request, sr)
+	if len(regulations) == 0 {
+		return nil
+	}
+	return &Diagnosis{
+		Fallback: true,
+		RuleID:   exe.ID,
+		Candidates: []*RuleMiss{
+			{RuleID: exe.ID, Method: string(exe.Method), Path: exe.Path.String(), Regulations: regulations},
+		},
+	}
+}
+
+// diagnoseRegulations 列出非默认regulation未命中的原因，按原因数量排序
+func (exe *Executor) diagnoseRegulations(ctx context.Context, request *fasthttp.Request, sr ScenarioRepository) []*RegulationMiss {
+	var misses []*RegulationMiss
+	shadowed := false
+	for i, regulation := range exe.Regulations {
+		if regulation.IsDefault {
+			shadowed = regulation.Filter.Filter(request)
+			continue
+		}
+		miss := &RegulationMiss{Index: i}
+		if reason := regulation.Scenario.mismatch(ctx, sr, exe.Namespace); reason != "" {
+			miss.Reasons = append(miss.Reasons, reason)
+		}
+		miss.Reasons = append(miss.Reasons, regulation.Filter.Mismatches(request)...)
+		if len(miss.Reasons) == 0 && shadowed {
+			miss.Reasons = append(miss.Reasons, "shadowed by the preceding default regulation")
+		}
+		misses = append(misses, miss)
+	}
+
+	sort.SliceStable(misses, func(i, j int) bool {
+		return len(misses[i].Reasons) < len(misses[j].Reasons)
+	})
+	return misses
+}
+
+// Summary 单行的诊断摘要，用于响应头
+func (d *Diagnosis) Summary() string {
+	var sb strings.Builder
+	if d.Fallback {
+		sb.WriteString("answered by default regulation of rule " + d.RuleID)
+	} else {
+		sb.WriteString("no rule matched")
+	}
+	if len(d.Candidates) == 0 {
+		return sb.String()
+	}
+
+	closest := d.Candidates[0]
+	if !d.Fallback {
+		sb.WriteString("; closest rule " + closest.RuleID + ": " + strings.Join(closest.Reasons, ", "))
+	}
+	if len(closest.Regulations) > 0 {
+		reg := closest.Regulations[0]
+		sb.WriteString("; closest regulation #" + strconv.Itoa(reg.Index) + ": " + strings.Join(reg.Reasons, ", "))
+	}
+	return sb.String()
+}
+
+// mismatch 场景状态不满足时返回原因
+func (s *Scenario) mismatch(ctx context.Context, sr ScenarioRepository, namespace string) string {
+	if s.satisfied(ctx, sr, namespace) {
+		return ""
+	}
+	current := ScenarioStateStarted
+	if sr != nil {
+		current = sr.GetState(ctx, namespace, s.Name)
+	}
+	return fmt.Sprintf("scenario %s expected state %q got %q", s.Name, s.RequiredState, current)
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func mustExecutor(t *testing.T, rule *Rule) *Executor {
+	_, _ = rule.SupplyID()
+	assert.NoError(t, rule.Validate())
+	exec, err := rule.To()
+	assert.NoError(t, err)
+	return exec
+}
+
+func TestDiagnoseUnmatched(t *testing.T) {
+	defaultRegulation := &Regulation{IsDefault: true, Template: &Template{StatusCode: 200, Body: "ok"}}
+	query := mustExecutor(t, &Rule{Path: "^/pay/query$", Method: "GET", Regulations: []*Regulation{defaultRegulation}})
+	notify := mustExecutor(t, &Rule{Path: "^/pay/notify$", Method: "POST", Regulations: []*Regulation{defaultRegulation}})
+	user := mustExecutor(t, &Rule{Path: "^/user/info$", Method: "GET", Regulations: []*Regulation{defaultRegulation}})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod("GET")
+	req.SetRequestURI("http://localhost/pay/notify")
+
+	diagnosis := DiagnoseUnmatched(context.Background(), []*Executor{user, query, notify}, req, nil)
+	assert.False(t, diagnosis.Fallback)
+	assert.Len(t, diagnosis.Candidates, 3)
+	assert.Equal(t, notify.ID, diagnosis.Candidates[0].RuleID)
+	assert.Equal(t, []string{`method expected "POST" got "GET"`}, diagnosis.Candidates[0].Reasons)
+	assert.Equal(t, query.ID, diagnosis.Candidates[1].RuleID) // 路径前缀更接近
+	assert.Equal(t, []string{`path "/pay/notify" does not match /^/pay/query$/`}, diagnosis.Candidates[1].Reasons)
+	assert.Equal(t, `no rule matched; closest rule `+notify.ID+`: method expected "POST" got "GET"`, diagnosis.Summary())
+}
+
+func TestExecutor_DiagnoseFallback(t *testing.T) {
+	exec := mustExecutor(t, &Rule{
+		Path:     "^/pay/query$",
+		Method:   "GET",
+		Scenario: &Scenario{Name: "polling"},
+		Regulations: []*Regulation{
+			{
+				Filter:   &Filter{Query: QueryFilterParams{ModeField: FilterModeExact, "sn": "123"}},
+				Template: &Template{StatusCode: 200, Body: "processing"},
+				Scenario: &Scenario{RequiredState: "Paying"},
+			},
+			{
+				Filter:   &Filter{Header: HeaderFilterParams{ModeField: FilterModeExact, "X-Env": "test"}},
+				Template: &Template{StatusCode: 200, Body: "test"},
+			},
+			{IsDefault: true, Template: &Template{StatusCode: 200, Body: "ok"}},
+		},
+	})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod("GET")
+	req.Header.Set("X-Env", "prod")
+	req.SetRequestURI("http://localhost/pay/query?sn=456")
+
+	chosen := exec.FindRegulationExecutor(context.Background(), req, nil)
+	diagnosis := exec.DiagnoseFallback(context.Background(), req, nil, chosen)
+	assert.True(t, diagnosis.Fallback)
+	assert.Equal(t, exec.ID, diagnosis.RuleID)
+	regulations := diagnosis.Candidates[0].Regulations
+	assert.Len(t, regulations, 2)
+	assert.Equal(t, 1, regulations[0].Index)
+	assert.Equal(t, []string{`header X-Env expected "test" got "prod"`}, regulations[0].Reasons)
+	assert.Equal(t, []string{
+		`scenario polling expected state "Paying" got "Started"`,
+		`query sn expected "123" got "456"`,
+	}, regulations[1].Reasons)
+
+	req.Header.Set("X-Env", "test")
+	chosen = exec.FindRegulationExecutor(context.Background(), req, nil)
+	assert.Nil(t, exec.DiagnoseFallback(context.Background(), req, nil, chosen))
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"html/template"
 	"math/rand"
@@ -24,6 +25,8 @@ const (
 	FilterModeKeyword FilterMode = "keyword"
 	// FilterModeRegular 正则表达式模式
 	FilterModeRegular FilterMode = "regular"
+	// FilterModeJSONPath JSONPath模式，仅适用于Body，key为JSONPath表达式，value为期望值或者/正则表达式/
+	FilterModeJSONPath FilterMode = "jsonpath"
 
 	// ModeField 筛选模式的字段名称
 	ModeField = "mode"
@@ -40,12 +43,19 @@ type (
 	// Executor 规则执行器
 	Executor struct {
 		ID          string
+		Namespace   string
 		Method      []byte
 		Path        *regexp.Regexp
 		Variable    map[string]interface{}
 		Weight      WeightPicker
 		Regulations []*RegulationExecutor
+		Priority    int
+		Scenario    *Scenario
 		Version     int
+		MTime       time.Time
+
+		literalPrefix string
+		isLiteral     bool
 	}
 
 	// WeightPicker 权重随机值选择器
@@ -63,6 +73,8 @@ type (
 		IsDefault bool
 		Filter    *FilterExecutor
 		Template  *TemplateExecutor
+		Fault     *WeightDice
+		Scenario  *Scenario
 	}
 
 	// TemplateExecutor 响应报文模板执行器
@@ -74,16 +86,19 @@ type (
 		headerTemplate   *template.Template
 		header           *fasthttp.ResponseHeader
 		body             []byte
+		delay            *DelayExecutor
 	}
 
 	// RenderContext 动态渲染的上下文
 	RenderContext struct {
-		Variable map[string]interface{}
-		Weight   map[string]string
-		Header   map[string]string
-		Query    map[string]string
-		Form     map[string]string
-		Json     map[string]interface{}
+		Variable   map[string]interface{}
+		Weight     map[string]string
+		Path       string
+		PathParams map[string]string
+		Header     map[string]string
+		Query      map[string]string
+		Form       map[string]string
+		Json       map[string]interface{}
 	}
 
 	// FilterExecutor 筛选执行器
@@ -91,13 +106,24 @@ type (
 		Query  *QueryFilterExecutor
 		Header *HeaderFilterExecutor
 		Body   *BodyFilterExecutor
+		AllOf  []*FilterExecutor
+		AnyOf  []*FilterExecutor
+		Not    *FilterExecutor
 	}
 
 	// BodyFilterExecutor Body报文筛选执行器
 	BodyFilterExecutor struct {
-		mode    FilterMode
-		regular *regexp.Regexp
-		keyword []byte
+		mode      FilterMode
+		regular   *regexp.Regexp
+		keyword   []byte
+		jsonPaths []*jsonPathMatcher
+	}
+
+	// jsonPathMatcher JSONPath筛选条件
+	jsonPathMatcher struct {
+		path     *JSONPath
+		expected string
+		regular  *regexp.Regexp
 	}
 
 	// HeaderFilterExecutor 请求头筛选执行器
@@ -247,11 +273,43 @@ func (bfe *BodyFilterExecutor) Filter(body []byte) bool {
 	case FilterModeRegular:
 		return bfe.regular.Match(body)
 
+	case FilterModeJSONPath:
+		return bfe.filterByJSONPath(extractJSONBody(body))
+
 	default:
 		return false
 	}
 }
 
+func (bfe *BodyFilterExecutor) filterByJSONPath(doc map[string]interface{}) bool {
+	if doc == nil {
+		return false
+	}
+	for _, matcher := range bfe.jsonPaths {
+		if !matcher.match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// match JSONPath命中的值中任意一个满足条件即视为匹配
+func (jpm *jsonPathMatcher) match(doc map[string]interface{}) bool {
+	for _, v := range jpm.path.Lookup(doc) {
+		s := jsonValueString(v)
+		if jpm.regular != nil {
+			if jpm.regular.MatchString(s) {
+				return true
+			}
+			continue
+		}
+		if s == jpm.expected {
+			return true
+		}
+	}
+	return false
+}
+
 // Filter 筛选函数
 func (fe *FilterExecutor) Filter(request *fasthttp.Request) bool {
 	if fe == nil {
@@ -267,13 +325,34 @@ func (fe *FilterExecutor) Filter(request *fasthttp.Request) bool {
 		return false
 	}
 
+	for _, sub := range fe.AllOf {
+		if !sub.Filter(request) {
+			return false
+		}
+	}
+	if len(fe.AnyOf) > 0 {
+		var matched bool
+		for _, sub := range fe.AnyOf {
+			if sub.Filter(request) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if fe.Not != nil && fe.Not.Filter(request) {
+		return false
+	}
+
 	return true
 }
 
-// Render 渲染函数
-func (te *TemplateExecutor) Render(ctx *fasthttp.RequestCtx, v map[string]interface{}, weight map[string]string) error {
+// Render 渲染函数，params为请求路径中正则捕获组的值
+func (te *TemplateExecutor) Render(ctx *fasthttp.RequestCtx, v map[string]interface{}, weight map[string]string, params map[string]string) error {
 	te.header.CopyTo(&ctx.Response.Header)
-	rc := &RenderContext{}
+	rc := &RenderContext{Path: string(ctx.Request.URI().Path()), PathParams: params}
 	if te.RenderHeader {
 		// 渲染header template
 		if err := te.handleHeaderTemplate(rc, ctx, v, weight); err != nil {
@@ -326,8 +405,13 @@ func (rc *RenderContext) parseParams(ctx *fasthttp.RequestCtx, v map[string]inte
 }
 
 // Render 渲染函数
-func (re *RegulationExecutor) Render(ctx *fasthttp.RequestCtx, v map[string]interface{}, w map[string]string) error {
-	return re.Template.Render(ctx, v, w)
+func (re *RegulationExecutor) Render(ctx *fasthttp.RequestCtx, v map[string]interface{}, w map[string]string, p map[string]string) error {
+	return re.Template.Render(ctx, v, w, p)
+}
+
+// Delay 计算响应前需要模拟的延迟
+func (re *RegulationExecutor) Delay(ctx *fasthttp.RequestCtx, v map[string]interface{}, w map[string]string, p map[string]string) time.Duration {
+	return re.Template.delay.Duration(ctx, v, w, p)
 }
 
 // Match 请求匹配函数
@@ -338,14 +422,50 @@ func (exe *Executor) Match(path, method []byte) bool {
 	return exe.Path.Match(path)
 }
 
-// FindRegulationExecutor 查找符合的报文规则执行器
-func (exe *Executor) FindRegulationExecutor(request *fasthttp.Request) *RegulationExecutor {
+// PathParams 提取请求路径中正则捕获组的值，命名捕获组以名称为key，所有捕获组同时以序号为key
+func (exe *Executor) PathParams(path []byte) map[string]string {
+	matches := exe.Path.FindSubmatch(path)
+	if len(matches) <= 1 {
+		return nil
+	}
+
+	names := exe.Path.SubexpNames()
+	params := make(map[string]string, 2*(len(matches)-1))
+	for i := 1; i < len(matches); i++ {
+		params[strconv.Itoa(i)] = string(matches[i])
+		if names[i] != "" {
+			params[names[i]] = string(matches[i])
+		}
+	}
+	return params
+}
+
+// Precedes 判断当前执行器的匹配顺序是否先于另一个执行器：
+// 优先级高的在前；优先级相同时，纯文本路径先于正则路径，文本前缀更长的在前；最后按ID排序保证结果稳定
+func (exe *Executor) Precedes(other *Executor) bool {
+	if exe.Priority != other.Priority {
+		return exe.Priority > other.Priority
+	}
+	if exe.isLiteral != other.isLiteral {
+		return exe.isLiteral
+	}
+	if len(exe.literalPrefix) != len(other.literalPrefix) {
+		return len(exe.literalPrefix) > len(other.literalPrefix)
+	}
+	return exe.ID < other.ID
+}
+
+// FindRegulationExecutor 查找符合的报文规则执行器，场景状态不满足的报文规则会被跳过
+func (exe *Executor) FindRegulationExecutor(ctx context.Context, request *fasthttp.Request, sr ScenarioRepository) *RegulationExecutor {
 	var reg *RegulationExecutor
 
 	for _, regulation := range exe.Regulations {
 		if regulation.IsDefault {
 			reg = regulation
 		}
+		if !regulation.Scenario.satisfied(ctx, sr, exe.Namespace) {
+			continue
+		}
 		if regulation.Filter.Filter(request) {
 			return regulation
 		}
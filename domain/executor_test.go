@@ -125,6 +125,44 @@ func TestEmptyFilterExecutor(t *testing.T) {
 	assert.True(t, fe.Filter(req))
 }
 
+func TestComposedFilterExecutor(t *testing.T) {
+	f := &Filter{
+		Header: HeaderFilterParams{"mode": "exact", "appid": "deepmock"},
+		AllOf: []*Filter{
+			{Query: QueryFilterParams{"mode": "regular", "sign": "^[0-9a-f]{8}$"}},
+		},
+		AnyOf: []*Filter{
+			{Body: BodyFilterParams{"mode": "keyword", "keyword": "refund"}},
+			{Query: QueryFilterParams{"mode": "exact", "type": "refund"}},
+		},
+		Not: &Filter{Header: HeaderFilterParams{"mode": "exact", "X-Env": "prod"}},
+	}
+	assert.NoError(t, f.Validate())
+	fe, err := f.To()
+	assert.NoError(t, err)
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.Set("appid", "deepmock")
+	req.SetRequestURI("/api?sign=0a1b2c3d")
+	req.SetBodyString(`{"type":"refund"}`)
+	assert.True(t, fe.Filter(req))
+
+	req.Header.Set("X-Env", "prod")
+	assert.False(t, fe.Filter(req))
+	req.Header.Del("X-Env")
+
+	req.SetBodyString(`{"type":"pay"}`)
+	assert.False(t, fe.Filter(req))
+	req.SetRequestURI("/api?sign=0a1b2c3d&type=refund")
+	assert.True(t, fe.Filter(req))
+
+	req.SetRequestURI("/api?sign=xyz&type=refund")
+	assert.False(t, fe.Filter(req))
+
+	assert.Error(t, (&Filter{AnyOf: []*Filter{{Query: QueryFilterParams{"sign": "x"}}}}).Validate())
+}
+
 func TestNewResponseTemplate(t *testing.T) {
 	res := &Template{
 		IsTemplate:     true,
@@ -262,6 +300,29 @@ func TestRequestMatch_Match(t *testing.T) {
 	assert.False(t, executor.Match([]byte("/api/va/create"), []byte("GET")))
 }
 
+func TestExecutor_PathParams(t *testing.T) {
+	rule := &Rule{
+		Path:   `/orders/(?P<sn>\w+)/items/(\d+)`,
+		Method: "GET",
+		Regulations: []*Regulation{
+			{
+				IsDefault: true,
+				Template:  &Template{IsTemplate: true, Body: `{{.Path}} {{.PathParams.sn}} {{index .PathParams "2"}}`},
+			}},
+	}
+	executor, err := rule.To()
+	assert.NoError(t, err)
+
+	params := executor.PathParams([]byte("/orders/SN001/items/3"))
+	assert.EqualValues(t, map[string]string{"1": "SN001", "sn": "SN001", "2": "3"}, params)
+	assert.Nil(t, executor.PathParams([]byte("/users")))
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders/SN001/items/3")
+	assert.NoError(t, executor.Regulations[0].Render(ctx, nil, nil, params))
+	assert.Equal(t, "/orders/SN001/items/3 SN001 3", string(ctx.Response.Body()))
+}
+
 func TestRuleExecutor_Minimal(t *testing.T) {
 	rule := &Rule{
 		Path:   "/api/v1/store/create",
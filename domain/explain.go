@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// RegulationEvaluation regulation对请求的筛选结果
+	RegulationEvaluation struct {
+		Index             int
+		IsDefault         bool
+		ScenarioSatisfied bool
+		Matched           bool // 场景状态及筛选器均满足
+		Chosen            bool // 实际用于响应的regulation
+		Reasons           []string
+	}
+)
+
+// EvaluateRegulations 逐个评估regulation对请求的筛选结果，不会修改场景状态
+func (exe *Executor) EvaluateRegulations(ctx context.Context, request *fasthttp.Request, sr ScenarioRepository) []*RegulationEvaluation {
+	chosen := exe.FindRegulationExecutor(ctx, request, sr)
+	evaluations := make([]*RegulationEvaluation, len(exe.Regulations))
+	for i, regulation := range exe.Regulations {
+		evaluation := &RegulationEvaluation{
+			Index:             i,
+			IsDefault:         regulation.IsDefault,
+			ScenarioSatisfied: regulation.Scenario.satisfied(ctx, sr, exe.Namespace),
+			Chosen:            regulation == chosen,
+		}
+		if reason := regulation.Scenario.mismatch(ctx, sr, exe.Namespace); reason != "" {
+			evaluation.Reasons = append(evaluation.Reasons, reason)
+		}
+		evaluation.Reasons = append(evaluation.Reasons, regulation.Filter.Mismatches(request)...)
+		evaluation.Matched = len(evaluation.Reasons) == 0
+		evaluations[i] = evaluation
+	}
+	return evaluations
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestExecutor_EvaluateRegulations(t *testing.T) {
+	exec := mustExecutor(t, &Rule{
+		Path:   "^/pay/refund$",
+		Method: "POST",
+		Regulations: []*Regulation{
+			{
+				Filter:   &Filter{Body: BodyFilterParams{ModeField: FilterModeJSONPath, "$.amount": "/^-/"}},
+				Template: &Template{StatusCode: 400, Body: "bad amount"},
+			},
+			{
+				Filter:   &Filter{Body: BodyFilterParams{ModeField: FilterModeKeyword, FilterModeKeyword: "refund_all"}},
+				Template: &Template{StatusCode: 200, Body: "refunded"},
+				Scenario: &Scenario{Name: "refund", RequiredState: "Paid", NewState: "Refunded"},
+			},
+			{IsDefault: true, Template: &Template{StatusCode: 200, Body: "ok"}},
+		},
+	})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod("POST")
+	req.Header.SetContentType("application/json")
+	req.SetBodyString(`{"amount": 100, "type": "refund_all"}`)
+
+	sr := mapScenarioRepository{}
+	evaluations := exec.EvaluateRegulations(context.Background(), req, sr)
+	assert.Len(t, evaluations, 3)
+	assert.False(t, evaluations[0].Matched)
+	assert.Equal(t, []string{`body $.amount ["100"] does not match /^-/`}, evaluations[0].Reasons)
+	assert.False(t, evaluations[1].ScenarioSatisfied)
+	assert.Equal(t, []string{`scenario refund expected state "Paid" got "Started"`}, evaluations[1].Reasons)
+	assert.True(t, evaluations[2].Matched)
+	assert.True(t, evaluations[2].Chosen)
+	assert.Empty(t, sr) // 演练不会切换场景状态
+
+	sr[DefaultNamespace+"/refund"] = "Paid"
+	evaluations = exec.EvaluateRegulations(context.Background(), req, sr)
+	assert.True(t, evaluations[1].Chosen)
+	assert.False(t, evaluations[2].Chosen)
+	assert.Equal(t, "Paid", sr[DefaultNamespace+"/refund"])
+}
@@ -52,14 +52,17 @@ func extractBodyAsParams(req *fasthttp.Request) (map[string]string, map[string]i
 		return p, nil
 
 	case bytes.HasPrefix(ct, jsonContentType):
-		j := make(map[string]interface{})
-		err := json.Unmarshal(req.Body(), &j)
-		if err != nil {
-			return nil, nil
-		}
-		return nil, j
+		return nil, extractJSONBody(req.Body())
 
 	default:
 		return nil, nil
 	}
 }
+
+func extractJSONBody(body []byte) map[string]interface{} {
+	j := make(map[string]interface{})
+	if err := json.Unmarshal(body, &j); err != nil {
+		return nil
+	}
+	return j
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"crypto/rand"
+	"errors"
+	mrand "math/rand"
+	"net"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// FaultNone 不注入故障，正常响应
+	FaultNone FaultType = "none"
+	// FaultConnectionReset 直接以RST断开连接
+	FaultConnectionReset FaultType = "connection_reset"
+	// FaultEmptyResponse 不返回任何数据，直接关闭连接
+	FaultEmptyResponse FaultType = "empty_response"
+	// FaultRandomDataThenClose 返回随机数据后关闭连接
+	FaultRandomDataThenClose FaultType = "random_data_then_close"
+	// FaultTruncatedBody 返回的Content-Length大于实际body长度，只返回部分body后关闭连接
+	FaultTruncatedBody FaultType = "truncated_body"
+
+	maxRandomFaultData = 1024
+)
+
+type (
+	// FaultType 故障类型
+	FaultType = string
+
+	// FaultFactor 故障权重值对象，key为故障类型，value为权重，可以用none表示正常响应的权重
+	FaultFactor map[string]uint
+)
+
+// Validate 校验函数
+func (ff FaultFactor) Validate() error {
+	if ff == nil {
+		return nil
+	}
+
+	var total uint
+	for k, v := range ff {
+		switch k {
+		case FaultNone, FaultConnectionReset, FaultEmptyResponse, FaultRandomDataThenClose, FaultTruncatedBody:
+			total += v
+		default:
+			return errors.New("unsupported fault type: " + k)
+		}
+	}
+	if total == 0 {
+		return errors.New("total weight of fault must be greater than 0")
+	}
+	return nil
+}
+
+// To 转换成WeightDice
+func (ff FaultFactor) To() *WeightDice {
+	if len(ff) == 0 {
+		return nil
+	}
+	return WeightFactor(ff).To()
+}
+
+// PickFault 根据权重选择本次请求需要注入的故障
+func (re *RegulationExecutor) PickFault() FaultType {
+	if re.Fault == nil {
+		return FaultNone
+	}
+	return re.Fault.Dice()
+}
+
+// InjectFault 通过劫持连接的方式注入故障，调用后fasthttp不会再发送ctx中的响应
+func InjectFault(ctx *fasthttp.RequestCtx, fault FaultType) {
+	var payload []byte
+	switch fault {
+	case FaultRandomDataThenClose:
+		payload = make([]byte, 1+mrand.Intn(maxRandomFaultData))
+		_, _ = rand.Read(payload)
+
+	case FaultTruncatedBody:
+		payload = truncateResponse(&ctx.Response)
+
+	case FaultConnectionReset, FaultEmptyResponse:
+
+	default:
+		return
+	}
+
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(c net.Conn) {
+		if len(payload) > 0 {
+			_, _ = c.Write(payload)
+		}
+		if fault == FaultConnectionReset {
+			if tc, ok := unwrapConn(c).(*net.TCPConn); ok {
+				_ = tc.SetLinger(0) // 关闭时发送RST而不是FIN
+			}
+		}
+		_ = c.Close()
+	})
+}
+
+// truncateResponse 生成Content-Length与实际body长度不一致的响应报文
+func truncateResponse(resp *fasthttp.Response) []byte {
+	body := resp.Body()
+	header := new(fasthttp.ResponseHeader)
+	resp.Header.CopyTo(header)
+	header.SetContentLength(len(body) + 1)
+
+	payload := append([]byte(nil), header.Header()...)
+	return append(payload, body[:len(body)/2]...)
+}
+
+// unwrapConn 获取被fasthttp或tls包装的底层连接
+func unwrapConn(c net.Conn) net.Conn {
+	for {
+		switch conn := c.(type) {
+		case *net.TCPConn:
+			return conn
+
+		case interface{ NetConn() net.Conn }:
+			c = conn.NetConn()
+
+		case interface{ UnsafeConn() net.Conn }: // fasthttp劫持的连接
+			c = conn.UnsafeConn()
+
+		default:
+			return c
+		}
+		if c == nil {
+			return nil
+		}
+	}
+}
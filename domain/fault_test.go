@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestFaultFactor_Validate(t *testing.T) {
+	var ff FaultFactor
+	assert.NoError(t, ff.Validate())
+	assert.Nil(t, ff.To())
+
+	assert.NoError(t, FaultFactor{FaultConnectionReset: 5, FaultNone: 95}.Validate())
+	assert.Error(t, FaultFactor{"timeout": 1}.Validate())
+	assert.Error(t, FaultFactor{FaultConnectionReset: 0}.Validate())
+
+	re := &RegulationExecutor{}
+	assert.Equal(t, FaultNone, re.PickFault())
+	re.Fault = FaultFactor{FaultEmptyResponse: 1}.To()
+	assert.Equal(t, FaultEmptyResponse, re.PickFault())
+}
+
+func serveFault(t *testing.T, fault FaultType) []byte {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("hello deepmock")
+		InjectFault(ctx, fault)
+	}}
+	go server.Serve(ln)
+
+	conn, err := ln.Dial()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: deepmock\r\n\r\n"))
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(conn)
+	return data
+}
+
+func TestInjectFault(t *testing.T) {
+	assert.Empty(t, serveFault(t, FaultEmptyResponse))
+	assert.NotEmpty(t, serveFault(t, FaultRandomDataThenClose))
+
+	data := serveFault(t, FaultTruncatedBody)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	assert.NoError(t, resp.Header.Read(bufio.NewReader(bytes.NewReader(data))))
+	assert.Equal(t, len("hello deepmock")+1, resp.Header.ContentLength())
+	assert.Contains(t, string(data), "\r\n\r\nhello d")
+	assert.NotContains(t, string(data), "hello deepmock")
+}
+
+type unsafeConn struct{ net.Conn }
+
+func (c *unsafeConn) UnsafeConn() net.Conn { return c.Conn }
+
+func TestUnwrapConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	assert.Equal(t, c1, unwrapConn(&unsafeConn{Conn: c1}))
+	assert.Equal(t, c1, unwrapConn(c1))
+	wrapped := &struct{ net.Conn }{Conn: c1}
+	assert.Equal(t, wrapped, unwrapConn(wrapped))
+}
+
+func TestInjectFault_ConnectionReset(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		InjectFault(ctx, FaultConnectionReset)
+	}}
+	go server.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: deepmock\r\n\r\n"))
+	assert.NoError(t, err)
+	data, err := io.ReadAll(conn)
+	assert.Empty(t, data)
+	assert.True(t, errors.Is(err, syscall.ECONNRESET), "unexpected error: %v", err)
+}
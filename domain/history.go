@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+type (
+	// RuleRevision 规则的历史版本实体，每次修改规则都会产生一个新的历史版本
+	RuleRevision struct {
+		RuleID   string
+		Revision int // 由存储库按规则从1开始递增分配
+		Action   RevisionAction
+		Author   string
+		CTime    time.Time
+		Rule     *Rule // 修改后的规则，删除时为nil
+	}
+
+	// RevisionAction 产生历史版本的操作
+	RevisionAction string
+)
+
+const (
+	// RevisionActionCreate 创建规则
+	RevisionActionCreate RevisionAction = "create"
+	// RevisionActionUpdate 全量或部分更新规则
+	RevisionActionUpdate RevisionAction = "update"
+	// RevisionActionDelete 删除规则
+	RevisionActionDelete RevisionAction = "delete"
+	// RevisionActionImport 导入规则
+	RevisionActionImport RevisionAction = "import"
+	// RevisionActionRecord 录制模式保存规则
+	RevisionActionRecord RevisionAction = "record"
+	// RevisionActionDisable 禁用规则
+	RevisionActionDisable RevisionAction = "disable"
+	// RevisionActionEnable 启用规则
+	RevisionActionEnable RevisionAction = "enable"
+	// RevisionActionRollback 回滚至历史版本
+	RevisionActionRollback RevisionAction = "rollback"
+)
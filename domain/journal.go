@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// JournalEntry 请求日志实体，记录mock服务收到的请求及匹配结果
+	JournalEntry struct {
+		ID              uint64
+		Namespace       string
+		Method          string
+		Path            string
+		Query           string
+		Header          map[string]string
+		Body            []byte
+		RuleID          string
+		RegulationIndex int // 未命中规则时为-1
+		Delay           time.Duration
+		Fault           FaultType
+		Proxied         bool
+		StatusCode      int
+		ReceivedAt      time.Time
+		Duration        time.Duration
+		Diagnosis       *Diagnosis // 开启诊断时，未命中规则或者回落到默认regulation的诊断结果
+	}
+
+	// JournalFilter 请求日志的查询条件，零值字段不参与筛选
+	JournalFilter struct {
+		Namespace string
+		RuleID    string
+		Method    string
+		Path      *regexp.Regexp
+		Since     time.Time
+		Until     time.Time
+		Limit     int // 只返回最近的N条
+
+		Unmatched bool // 只返回带有诊断结果的请求
+	}
+)
+
+// NewJournalEntry 从请求中复制需要记录的内容，需要在请求被修改之前调用
+func NewJournalEntry(id uint64, namespace string, ctx *fasthttp.RequestCtx) *JournalEntry {
+	entry := &JournalEntry{
+		ID:              id,
+		Namespace:       namespace,
+		Method:          string(ctx.Request.Header.Method()),
+		Path:            string(ctx.Request.URI().Path()),
+		Query:           string(ctx.Request.URI().QueryString()),
+		Header:          map[string]string{},
+		Body:            append([]byte(nil), ctx.Request.Body()...),
+		RegulationIndex: -1,
+		ReceivedAt:      ctx.Time(),
+	}
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		entry.Header[string(key)] = string(value)
+	})
+	return entry
+}
+
+// Complete 记录响应结果及耗时
+func (je *JournalEntry) Complete(resp *fasthttp.Response) {
+	je.StatusCode = resp.StatusCode()
+	je.Duration = time.Since(je.ReceivedAt)
+}
+
+// Match 判断请求日志是否满足查询条件
+func (jf JournalFilter) Match(entry *JournalEntry) bool {
+	if jf.Unmatched && entry.Diagnosis == nil {
+		return false
+	}
+	if jf.Namespace != "" && entry.Namespace != jf.Namespace {
+		return false
+	}
+	if jf.RuleID != "" && entry.RuleID != jf.RuleID {
+		return false
+	}
+	if jf.Method != "" && entry.Method != jf.Method {
+		return false
+	}
+	if jf.Path != nil && !jf.Path.MatchString(entry.Path) {
+		return false
+	}
+	if !jf.Since.IsZero() && entry.ReceivedAt.Before(jf.Since) {
+		return false
+	}
+	if !jf.Until.IsZero() && entry.ReceivedAt.After(jf.Until) {
+		return false
+	}
+	return true
+}
+
+// RegulationIndex 返回报文规则执行器在规则中的下标，不存在时返回-1
+func (exe *Executor) RegulationIndex(re *RegulationExecutor) int {
+	for i, regulation := range exe.Regulations {
+		if regulation == re {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+type (
+	// JSONPath 编译后的JSONPath表达式，支持的语法子集：
+	// $ 根节点、.name 子节点、['name'] 子节点、[n] 数组下标、* 或 [*] 通配
+	JSONPath struct {
+		expr     string
+		segments []jsonPathSegment
+	}
+
+	jsonPathSegment struct {
+		key      string
+		index    int
+		isIndex  bool
+		wildcard bool
+	}
+)
+
+// CompileJSONPath 编译JSONPath表达式，表达式可省略开头的$
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	jp := &JSONPath{expr: expr}
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+	} else if s != "" && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, errors.New("bad jsonpath expression: " + expr)
+			}
+			if name == "*" {
+				jp.segments = append(jp.segments, jsonPathSegment{wildcard: true})
+			} else {
+				jp.segments = append(jp.segments, jsonPathSegment{key: name})
+			}
+			s = s[end:]
+
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, errors.New("bad jsonpath expression: " + expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+
+			switch {
+			case inner == "*":
+				jp.segments = append(jp.segments, jsonPathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				jp.segments = append(jp.segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, errors.New("bad jsonpath expression: " + expr)
+				}
+				jp.segments = append(jp.segments, jsonPathSegment{index: index, isIndex: true})
+			}
+
+		default:
+			return nil, errors.New("bad jsonpath expression: " + expr)
+		}
+	}
+	return jp, nil
+}
+
+// String 返回原始表达式
+func (jp *JSONPath) String() string {
+	return jp.expr
+}
+
+// Lookup 查找表达式在文档中对应的所有值
+func (jp *JSONPath) Lookup(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, seg := range jp.segments {
+		var next []interface{}
+		for _, node := range current {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				if seg.wildcard {
+					for _, v := range n {
+						next = append(next, v)
+					}
+				} else if v, ok := n[seg.key]; ok && !seg.isIndex {
+					next = append(next, v)
+				}
+
+			case []interface{}:
+				switch {
+				case seg.wildcard:
+					next = append(next, n...)
+				case seg.isIndex:
+					index := seg.index
+					if index < 0 {
+						index += len(n)
+					}
+					if index >= 0 && index < len(n) {
+						next = append(next, n[index])
+					}
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// jsonValueString 将JSON值转换为用于比较的字符串
+func jsonValueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case nil:
+		return "null"
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileJSONPath(t *testing.T) {
+	doc := extractJSONBody([]byte(`{"biz":{"type":"refund","amount":10.5,"items":[{"sku":"a"},{"sku":"b"}]},"ok":true}`))
+
+	tests := []struct {
+		expr   string
+		wanted []interface{}
+	}{
+		{"$.biz.type", []interface{}{"refund"}},
+		{"biz.type", []interface{}{"refund"}},
+		{"$['biz']['amount']", []interface{}{10.5}},
+		{"$.biz.items[1].sku", []interface{}{"b"}},
+		{"$.biz.items[-1].sku", []interface{}{"b"}},
+		{"$.biz.items[*].sku", []interface{}{"a", "b"}},
+		{"$.ok", []interface{}{true}},
+		{"$.biz.missing", nil},
+		{"$.biz.items[5]", nil},
+	}
+	for _, test := range tests {
+		jp, err := CompileJSONPath(test.expr)
+		assert.NoError(t, err)
+		assert.EqualValues(t, test.wanted, jp.Lookup(doc), test.expr)
+	}
+
+	for _, bad := range []string{"$.", "$[", "$[abc]", "$biz"} {
+		_, err := CompileJSONPath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestBodyFilter_FilterByJSONPath(t *testing.T) {
+	bf, err := BodyFilterParams{"mode": "jsonpath", "$.biz.type": "refund", "$.biz.amount": "/^1[0-9]\\./"}.To()
+	assert.NoError(t, err)
+	assert.True(t, bf.Filter([]byte(`{"biz":{"type":"refund","amount":10.5}}`)))
+	assert.False(t, bf.Filter([]byte(`{"biz":{"type":"pay","amount":10.5}}`)))
+	assert.False(t, bf.Filter([]byte(`{"biz":{"type":"refund","amount":20.5}}`)))
+	assert.False(t, bf.Filter([]byte(`not a json`)))
+
+	f := &Filter{Body: BodyFilterParams{"mode": "jsonpath", "$.biz[": "refund"}}
+	assert.Error(t, f.Validate())
+	f = &Filter{Body: BodyFilterParams{"mode": "jsonpath", "$.biz.type": "/[/"}}
+	assert.Error(t, f.Validate())
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Mismatches 返回请求未满足筛选条件的原因，全部满足时返回空
+func (fe *FilterExecutor) Mismatches(request *fasthttp.Request) []string {
+	if fe == nil {
+		return nil
+	}
+
+	var reasons []string
+	reasons = append(reasons, fe.Header.mismatches(&request.Header)...)
+	reasons = append(reasons, fe.Query.mismatches(request.URI().QueryArgs())...)
+	reasons = append(reasons, fe.Body.mismatches(request.Body())...)
+
+	for i, sub := range fe.AllOf {
+		for _, reason := range sub.Mismatches(request) {
+			reasons = append(reasons, "all_of["+strconv.Itoa(i)+"]: "+reason)
+		}
+	}
+	if len(fe.AnyOf) > 0 {
+		var closest []string
+		closestIndex := -1
+		for i, sub := range fe.AnyOf {
+			sr := sub.Mismatches(request)
+			if len(sr) == 0 {
+				closestIndex = -1
+				closest = nil
+				break
+			}
+			if closestIndex == -1 || len(sr) < len(closest) {
+				closestIndex, closest = i, sr
+			}
+		}
+		if closestIndex != -1 {
+			reasons = append(reasons, "any_of: no sub filter matched")
+			for _, reason := range closest {
+				reasons = append(reasons, "any_of["+strconv.Itoa(closestIndex)+"]: "+reason)
+			}
+		}
+	}
+	if fe.Not != nil && fe.Not.Filter(request) {
+		reasons = append(reasons, "not: sub filter matched")
+	}
+	return reasons
+}
+
+// paramsMismatches header与query筛选共用的比较逻辑
+func paramsMismatches(kind string, mode FilterMode, params map[string][]byte, regulars map[string]*regexp.Regexp, peek func(string) []byte) []string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var reasons []string
+	for _, k := range keys {
+		expected, got := params[k], peek(k)
+		switch mode {
+		case FilterModeExact:
+			if !bytes.Equal(got, expected) {
+				reasons = append(reasons, fmt.Sprintf("%s %s expected %q got %q", kind, k, expected, got))
+			}
+		case FilterModeKeyword:
+			if !bytes.Contains(got, expected) {
+				reasons = append(reasons, fmt.Sprintf("%s %s %q does not contain %q", kind, k, got, expected))
+			}
+		case FilterModeRegular:
+			if !regulars[k].Match(got) {
+				reasons = append(reasons, fmt.Sprintf("%s %s %q does not match /%s/", kind, k, got, regulars[k]))
+			}
+		default:
+			return []string{"unsupported " + kind + " filter mode " + mode}
+		}
+	}
+	return reasons
+}
+
+func (hfe *HeaderFilterExecutor) mismatches(header *fasthttp.RequestHeader) []string {
+	if hfe.Filter(header) {
+		return nil
+	}
+	return paramsMismatches("header", hfe.mode, hfe.params, hfe.regulars, header.Peek)
+}
+
+func (qfe *QueryFilterExecutor) mismatches(args *fasthttp.Args) []string {
+	if qfe.Filter(args) {
+		return nil
+	}
+	return paramsMismatches("query", qfe.mode, qfe.params, qfe.regulars, args.Peek)
+}
+
+func (bfe *BodyFilterExecutor) mismatches(body []byte) []string {
+	if bfe.Filter(body) {
+		return nil
+	}
+
+	switch bfe.mode {
+	case FilterModeKeyword:
+		return []string{fmt.Sprintf("body keyword %q absent", bfe.keyword)}
+
+	case FilterModeRegular:
+		return []string{fmt.Sprintf("body does not match /%s/", bfe.regular)}
+
+	case FilterModeJSONPath:
+		doc := extractJSONBody(body)
+		if doc == nil {
+			return []string{"body is not a json object"}
+		}
+		var reasons []string
+		for _, matcher := range bfe.jsonPaths {
+			if matcher.match(doc) {
+				continue
+			}
+			values := matcher.path.Lookup(doc)
+			if len(values) == 0 {
+				reasons = append(reasons, fmt.Sprintf("body %s not found", matcher.path))
+				continue
+			}
+			got := make([]string, len(values))
+			for i, v := range values {
+				got[i] = jsonValueString(v)
+			}
+			if matcher.regular != nil {
+				reasons = append(reasons, fmt.Sprintf("body %s %q does not match /%s/", matcher.path, got, matcher.regular))
+			} else {
+				reasons = append(reasons, fmt.Sprintf("body %s expected %q got %q", matcher.path, matcher.expected, got))
+			}
+		}
+		return reasons
+
+	default:
+		return []string{"unsupported body filter mode " + bfe.mode}
+	}
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/wosai/deepmock/misc"
+)
+
+// DefaultNamespace 未指定命名空间时规则所属的命名空间，其中规则的ID与引入命名空间之前保持一致
+const DefaultNamespace = "default"
+
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// NormalizeNamespace 空命名空间视为默认命名空间
+func NormalizeNamespace(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
+
+// ValidateNamespace 校验命名空间名称，只允许字母、数字、下划线、点及中划线，最长64个字符
+func ValidateNamespace(namespace string) error {
+	if !namespacePattern.MatchString(namespace) {
+		return errors.New("invalid namespace: " + namespace)
+	}
+	return nil
+}
+
+// GenRuleID 生成规则ID，规则ID在命名空间内由path与method唯一确定
+func GenRuleID(namespace, path, method string) string {
+	if NormalizeNamespace(namespace) == DefaultNamespace {
+		return misc.GenID([]byte(path), []byte(method))
+	}
+	return misc.GenNamespacedID([]byte(namespace), []byte(path), []byte(method))
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/misc"
+)
+
+func TestGenRuleID(t *testing.T) {
+	legacy := misc.GenID([]byte("/whoami"), []byte("GET"))
+	assert.Equal(t, legacy, GenRuleID("", "/whoami", "GET"))
+	assert.Equal(t, legacy, GenRuleID(DefaultNamespace, "/whoami", "get"))
+	assert.NotEqual(t, legacy, GenRuleID("payment", "/whoami", "GET"))
+}
+
+func TestRule_ValidateNamespace(t *testing.T) {
+	rule := &Rule{Path: "/whoami", Method: "get", Regulations: []*Regulation{{IsDefault: true, Template: &Template{}}}}
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, DefaultNamespace, rule.Namespace)
+
+	rule = &Rule{Namespace: "payment", Path: "/whoami", Method: "GET", Regulations: rule.Regulations}
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, GenRuleID("payment", "/whoami", "GET"), rule.ID)
+
+	rule.Namespace = DefaultNamespace
+	assert.EqualError(t, rule.Validate(), "invalid rule id")
+	rule.Namespace = "pay ment"
+	assert.Error(t, rule.Validate())
+}
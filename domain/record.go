@@ -0,0 +1,281 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// RecordedExchange 录制到的一次请求与上游响应
+	RecordedExchange struct {
+		Method   string
+		Path     string
+		Query    map[string]string
+		Form     map[string]string
+		Json     map[string]string
+		Template *Template
+	}
+
+	// RecordingSession 录制会话实体，会话期间转发至上游的该命名空间的请求都会被录制成规则
+	RecordingSession struct {
+		ID         string
+		Namespace  string
+		PathPrefix string
+		StartedAt  time.Time
+		stoppedAt  time.Time
+		exchanges  map[string][]*RecordedExchange // key为规则ID
+		mu         sync.Mutex
+	}
+)
+
+var (
+	skippedRecordHeaders = map[string]struct{}{
+		fasthttp.HeaderContentLength:    {},
+		fasthttp.HeaderDate:             {},
+		fasthttp.HeaderConnection:       {},
+		fasthttp.HeaderTransferEncoding: {},
+	}
+)
+
+// NewRecordedExchange 从转发的请求及上游响应中提取录制内容
+func NewRecordedExchange(req *fasthttp.Request, resp *fasthttp.Response) *RecordedExchange {
+	ex := &RecordedExchange{
+		Method: string(req.Header.Method()),
+		Path:   string(req.URI().Path()),
+		Query:  extractQueryAsParams(req),
+	}
+	var j map[string]interface{}
+	ex.Form, j = extractBodyAsParams(req)
+	if j != nil {
+		ex.Json = make(map[string]string, len(j))
+		for k, v := range j {
+			ex.Json[k] = jsonValueString(v)
+		}
+	}
+
+	tmp := &Template{StatusCode: resp.StatusCode(), Header: map[string]string{}}
+	resp.Header.VisitAll(func(key, value []byte) {
+		if _, skipped := skippedRecordHeaders[string(key)]; !skipped {
+			tmp.Header[string(key)] = string(value)
+		}
+	})
+	if body := resp.Body(); utf8.Valid(body) && len(resp.Header.Peek(fasthttp.HeaderContentEncoding)) == 0 {
+		tmp.Body = string(body)
+	} else {
+		tmp.B64EncodedBody = base64.StdEncoding.EncodeToString(body)
+	}
+	ex.Template = tmp
+	return ex
+}
+
+// signature 请求参数的签名，参数完全相同的请求视为同一请求
+func (ex *RecordedExchange) signature() string {
+	var buf bytes.Buffer
+	for _, params := range []map[string]string{ex.Query, ex.Form, ex.Json} {
+		keys := sortedKeys(params)
+		for _, k := range keys {
+			buf.WriteString(url.QueryEscape(k))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(params[k]))
+			buf.WriteByte('&')
+		}
+		buf.WriteByte('|')
+	}
+	return buf.String()
+}
+
+// recordedPath 录制规则只精确匹配录制到的路径
+func recordedPath(path string) string {
+	return "^" + regexp.QuoteMeta(path) + "$"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// differentKeys 返回在多次请求中取值不完全相同的参数名
+func differentKeys(exchanges []*RecordedExchange, params func(*RecordedExchange) map[string]string) []string {
+	union := map[string]string{}
+	for _, ex := range exchanges {
+		for k := range params(ex) {
+			union[k] = ""
+		}
+	}
+
+	var keys []string
+	for _, k := range sortedKeys(union) {
+		first := params(exchanges[0])[k]
+		for _, ex := range exchanges[1:] {
+			if params(ex)[k] != first {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// BuildRecordedRule 将同一接口的多次录制结果合并成规则：第一次录制的响应作为默认响应，
+// 其余响应按照取值不同的query、body参数生成筛选器；无法生成筛选器或筛选器重复的响应被忽略，避免遮蔽其他响应
+func BuildRecordedRule(exchanges []*RecordedExchange) *Rule {
+	first := exchanges[0]
+	rule := &Rule{
+		Path:   recordedPath(first.Path),
+		Method: first.Method,
+		Regulations: []*Regulation{
+			{IsDefault: true, Template: first.Template},
+		},
+	}
+	rule.SupplyID()
+	if len(exchanges) == 1 {
+		return rule
+	}
+
+	queryKeys := differentKeys(exchanges, func(ex *RecordedExchange) map[string]string { return ex.Query })
+	formKeys := differentKeys(exchanges, func(ex *RecordedExchange) map[string]string { return ex.Form })
+	jsonKeys := differentKeys(exchanges, func(ex *RecordedExchange) map[string]string { return ex.Json })
+
+	for _, ex := range exchanges[1:] {
+		filter := new(Filter)
+		if len(queryKeys) > 0 {
+			filter.Query = QueryFilterParams{ModeField: FilterModeExact}
+			for _, k := range queryKeys {
+				filter.Query[k] = ex.Query[k]
+			}
+		}
+		if len(jsonKeys) > 0 && ex.Json != nil {
+			body := BodyFilterParams{ModeField: FilterModeJSONPath}
+			for _, k := range jsonKeys {
+				if strings.ContainsAny(k, "'\"]") {
+					continue
+				}
+				body["$['"+k+"']"] = "/^" + regexp.QuoteMeta(ex.Json[k]) + "$/"
+			}
+			if len(body) > 1 {
+				filter.Body = body
+			}
+		}
+		for _, k := range formKeys {
+			pattern := "(^|&)" + regexp.QuoteMeta(url.QueryEscape(k)+"="+url.QueryEscape(ex.Form[k])) + "(&|$)"
+			filter.AllOf = append(filter.AllOf, &Filter{Body: BodyFilterParams{ModeField: FilterModeRegular, FilterModeRegular: pattern}})
+		}
+		if filter.Query == nil && filter.Body == nil && len(filter.AllOf) == 0 {
+			continue
+		}
+		if duplicatedFilter(rule.Regulations, filter) {
+			continue
+		}
+		rule.Regulations = append(rule.Regulations, &Regulation{Filter: filter, Template: ex.Template})
+	}
+	return rule
+}
+
+func duplicatedFilter(regulations []*Regulation, filter *Filter) bool {
+	for _, regulation := range regulations {
+		if regulation.Filter != nil && reflect.DeepEqual(regulation.Filter, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecordingSession 工厂函数
+func NewRecordingSession(namespace, prefix string) *RecordingSession {
+	return &RecordingSession{
+		ID:         uuid.New().String(),
+		Namespace:  NormalizeNamespace(namespace),
+		PathPrefix: prefix,
+		StartedAt:  time.Now(),
+		exchanges:  map[string][]*RecordedExchange{},
+	}
+}
+
+// Active 会话是否仍在录制中
+func (rs *RecordingSession) Active() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.stoppedAt.IsZero()
+}
+
+// StoppedAt 停止录制的时间，录制中时为零值
+func (rs *RecordingSession) StoppedAt() time.Time {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.stoppedAt
+}
+
+// Covers 请求是否在录制范围内，路径前缀按完整的路径片段匹配
+func (rs *RecordingSession) Covers(namespace string, path []byte) bool {
+	return NormalizeNamespace(namespace) == rs.Namespace && HasPathPrefix(path, rs.PathPrefix)
+}
+
+// HasPathPrefix 按完整的路径片段匹配前缀，如 /pay 匹配 /pay 及 /pay/query，但不匹配 /payment；前缀末尾的/可省略
+func HasPathPrefix(path []byte, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !bytes.HasPrefix(path, []byte(prefix)) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// Stop 停止录制
+func (rs *RecordingSession) Stop() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.stoppedAt.IsZero() {
+		rs.stoppedAt = time.Now()
+	}
+}
+
+// Record 记录一次请求，返回合并了该接口所有录制内容的规则
+func (rs *RecordingSession) Record(ex *RecordedExchange) *Rule {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rid := GenRuleID(rs.Namespace, recordedPath(ex.Path), ex.Method)
+	exchanges := rs.exchanges[rid]
+	replaced := false
+	for i, recorded := range exchanges {
+		if recorded.signature() == ex.signature() { // 相同的请求只保留最新的响应
+			exchanges[i] = ex
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		exchanges = append(exchanges, ex)
+	}
+	rs.exchanges[rid] = exchanges
+	rule := BuildRecordedRule(exchanges)
+	rule.Namespace, rule.ID = rs.Namespace, rid
+	return rule
+}
+
+// RuleIDs 返回会话中录制的规则ID
+func (rs *RecordingSession) RuleIDs() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	ids := make([]string, 0, len(rs.exchanges))
+	for id := range rs.exchanges {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
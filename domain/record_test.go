@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func newRecordedExchange(method, uri, contentType, body string, status int, respBody []byte) *RecordedExchange {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	if contentType != "" {
+		req.Header.SetContentType(contentType)
+		req.SetBodyString(body)
+	}
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.SetStatusCode(status)
+	resp.Header.Set("X-Upstream", "staging")
+	resp.SetBody(respBody)
+	return NewRecordedExchange(req, resp)
+}
+
+func TestNewRecordedExchange(t *testing.T) {
+	ex := newRecordedExchange("GET", "http://localhost/pay/query?sn=1", "", "", 200, []byte(`{"status":"ok"}`))
+	assert.Equal(t, "/pay/query", ex.Path)
+	assert.Equal(t, "1", ex.Query["sn"])
+	assert.Equal(t, `{"status":"ok"}`, ex.Template.Body)
+	assert.Equal(t, "staging", ex.Template.Header["X-Upstream"])
+	_, ok := ex.Template.Header[fasthttp.HeaderContentLength]
+	assert.False(t, ok)
+
+	binary := newRecordedExchange("GET", "http://localhost/pay/qrcode", "", "", 200, []byte{0xff, 0xfe, 0x00})
+	assert.Empty(t, binary.Template.Body)
+	assert.Equal(t, "//4A", binary.Template.B64EncodedBody)
+}
+
+func TestRecordingSession_Record(t *testing.T) {
+	session := NewRecordingSession("", "/pay")
+	assert.True(t, session.Covers(DefaultNamespace, []byte("/pay/query")))
+	assert.False(t, session.Covers(DefaultNamespace, []byte("/user/info")))
+	assert.False(t, session.Covers("payment", []byte("/pay/query")))
+
+	session.Record(newRecordedExchange("GET", "http://localhost/pay/query?sn=1&appid=wx", "", "", 200, []byte("processing")))
+	session.Record(newRecordedExchange("GET", "http://localhost/pay/query?sn=1&appid=wx", "", "", 200, []byte("success")))
+	rule := session.Record(newRecordedExchange("GET", "http://localhost/pay/query?sn=2&appid=wx", "", "", 404, []byte("not found")))
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, []string{rule.ID}, session.RuleIDs())
+
+	exec, err := rule.To()
+	assert.NoError(t, err)
+	assert.True(t, exec.Path.MatchString("/pay/query"))
+	assert.False(t, exec.Path.MatchString("/pay/query/detail"))
+	assert.Len(t, exec.Regulations, 2)
+	assert.Equal(t, "success", rule.Regulations[0].Template.Body) // 相同请求保留最新的响应
+	assert.Equal(t, QueryFilterParams{ModeField: FilterModeExact, "sn": "2"}, rule.Regulations[1].Filter.Query)
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI("http://localhost/pay/query?sn=2&appid=wx")
+	assert.True(t, exec.Regulations[1].Filter.Filter(req))
+	req.SetRequestURI("http://localhost/pay/query?sn=1&appid=wx")
+	assert.False(t, exec.Regulations[1].Filter.Filter(req))
+
+	session.Stop()
+	assert.False(t, session.Active())
+	assert.False(t, session.StoppedAt().IsZero())
+}
+
+func TestRecordingSession_Namespace(t *testing.T) {
+	session := NewRecordingSession("payment", "/pay")
+	assert.True(t, session.Covers("payment", []byte("/pay/query")))
+	assert.False(t, session.Covers(DefaultNamespace, []byte("/pay/query")))
+	assert.True(t, session.Covers("payment", []byte("/pay")))
+	assert.False(t, session.Covers("payment", []byte("/payment/query")))
+	assert.True(t, NewRecordingSession("payment", "/pay/").Covers("payment", []byte("/pay/query")))
+	assert.True(t, NewRecordingSession("payment", "/").Covers("payment", []byte("/payment/query")))
+
+	rule := session.Record(newRecordedExchange("GET", "http://localhost/pay/query?sn=1", "", "", 200, []byte("success")))
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, "payment", rule.Namespace)
+	assert.Equal(t, GenRuleID("payment", rule.Path, "GET"), rule.ID)
+	assert.NotEqual(t, GenRuleID(DefaultNamespace, rule.Path, "GET"), rule.ID)
+}
+
+func TestBuildRecordedRule_Body(t *testing.T) {
+	rule := BuildRecordedRule([]*RecordedExchange{
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn":"1","amount":100}`, 200, []byte("ok")),
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn":"1","amount":-1}`, 400, []byte("bad amount")),
+	})
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, BodyFilterParams{ModeField: FilterModeJSONPath, "$['amount']": `/^-1$/`}, rule.Regulations[1].Filter.Body)
+
+	exec, err := rule.To()
+	assert.NoError(t, err)
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod("POST")
+	req.Header.SetContentType("application/json")
+	req.SetBodyString(`{"sn":"2","amount":-1}`)
+	assert.True(t, exec.Regulations[1].Filter.Filter(req))
+	req.SetBodyString(`{"sn":"2","amount":-10}`)
+	assert.False(t, exec.Regulations[1].Filter.Filter(req))
+
+	form := BuildRecordedRule([]*RecordedExchange{
+		newRecordedExchange("POST", "http://localhost/pay/notify", "application/x-www-form-urlencoded", "sn=1&status=ok", 200, []byte("ok")),
+		newRecordedExchange("POST", "http://localhost/pay/notify", "application/x-www-form-urlencoded", "sn=1&status=fail", 200, []byte("fail")),
+	})
+	exec, err = form.To()
+	assert.NoError(t, err)
+	req.Header.SetContentType("application/x-www-form-urlencoded")
+	req.SetBodyString("status=fail&sn=3")
+	assert.True(t, exec.Regulations[1].Filter.Filter(req))
+	req.SetBodyString("status=failed&sn=3")
+	assert.False(t, exec.Regulations[1].Filter.Filter(req))
+}
+
+func TestBuildRecordedRule_EmptyFilter(t *testing.T) {
+	// 取值不同的参数都无法生成筛选器时，只保留默认响应
+	rule := BuildRecordedRule([]*RecordedExchange{
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn'":"1"}`, 200, []byte("ok")),
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn'":"2"}`, 400, []byte("bad sn")),
+	})
+	assert.NoError(t, rule.Validate())
+	assert.Len(t, rule.Regulations, 1)
+	assert.Equal(t, "ok", rule.Regulations[0].Template.Body)
+
+	// 筛选器重复时只保留第一个
+	rule = BuildRecordedRule([]*RecordedExchange{
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn":"1","x'y":"1"}`, 200, []byte("ok")),
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn":"2","x'y":"1"}`, 400, []byte("first")),
+		newRecordedExchange("POST", "http://localhost/pay/refund", "application/json", `{"sn":"2","x'y":"2"}`, 400, []byte("second")),
+	})
+	assert.NoError(t, rule.Validate())
+	assert.Len(t, rule.Regulations, 2)
+	assert.Equal(t, "first", rule.Regulations[1].Template.Body)
+}
@@ -1,21 +1,98 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// AnyVersion 删除规则时不校验版本号
+const AnyVersion = -1
+
+var (
+	// ErrVersionConflict 乐观锁冲突，规则已被其他人修改或删除
+	ErrVersionConflict = errors.New("rule version conflict")
+	// ErrRuleNotExist 规则不存在
+	ErrRuleNotExist = errors.New("cannot find rule")
+)
 
 type (
 	// RuleRepository 规则存储库接口定义
 	RuleRepository interface {
 		CreateRule(context.Context, *Rule) error
 		UpdateRule(context.Context, *Rule) error
-		GetRuleByID(context.Context, string) (*Rule, error)
-		DeleteRule(context.Context, string) error
+		GetRuleByID(context.Context, string) (*Rule, error) // 规则不存在时返回*RuleNotExistError
+		DeleteRule(context.Context, string, int) error // 版本号为AnyVersion时不校验且规则不存在时不报错，否则版本号不一致或规则不存在时返回*VersionConflictError
 		Export(context.Context) ([]*Rule, error)
 		Import(context.Context, ...*Rule) error
+		Replace(context.Context, []*Rule, []string, map[string]int) error // 导入规则并删除指定ID的其他规则，整体成功或失败；最后一个参数为规则ID到本次修改所基于的版本号，版本号不一致或规则不存在时返回*VersionConflictError
+	}
+
+	// IncrementalRuleRepository 支持增量导出的规则存储库，水位线为零值时导出所有规则
+	IncrementalRuleRepository interface {
+		RuleRepository
+		ExportChanges(context.Context, time.Time) (*RuleChanges, error)
+	}
+
+	// RuleHistoryRepository 规则历史版本存储库接口定义，SaveRevision负责分配版本号
+	RuleHistoryRepository interface {
+		SaveRevision(context.Context, *RuleRevision) error
+		ListRevisions(context.Context, string) ([]*RuleRevision, error)
+		GetRevision(context.Context, string, int) (*RuleRevision, error)
 	}
 
 	// ExecutorRepository 执行器接口定义
 	ExecutorRepository interface {
-		FindExecutor(context.Context, []byte, []byte) (*Executor, bool)
+		FindExecutor(context.Context, string, []byte, []byte) (*Executor, bool) // 在指定命名空间中查询执行器
+		ListExecutors(context.Context) []*Executor
 		ImportAll(context.Context, ...*Executor)
+		Apply(context.Context, []*Executor, []string)
+	}
+
+	// ScenarioRepository 场景状态存储库接口定义，场景按命名空间隔离，第一个字符串参数为命名空间
+	ScenarioRepository interface {
+		GetState(context.Context, string, string) string
+		CompareAndSet(context.Context, string, ...ScenarioTransition) bool // 所有场景的当前状态都满足要求时才一并切换
+		ListStates(context.Context, string) map[string]string
+		Reset(context.Context, string, ...string)
+	}
+
+	// JournalRepository 请求日志存储库接口定义
+	JournalRepository interface {
+		Append(context.Context, *JournalEntry)
+		List(context.Context, JournalFilter) []*JournalEntry
+		Clear(context.Context, JournalFilter) // 删除满足条件的请求日志
 	}
 )
+
+// VersionConflictError 更新规则时的乐观锁冲突，Expected为本次修改所基于的版本号
+type VersionConflictError struct {
+	RuleID   string
+	Expected int
+}
+
+// Error error的实现
+func (e *VersionConflictError) Error() string {
+	return ErrVersionConflict.Error() + ": rule " + e.RuleID + " is not at version " + strconv.Itoa(e.Expected)
+}
+
+// Unwrap 支持errors.Is(err, ErrVersionConflict)
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// RuleNotExistError 按ID查询的规则不存在
+type RuleNotExistError struct {
+	RuleID string
+}
+
+// Error error的实现
+func (e *RuleNotExistError) Error() string {
+	return ErrRuleNotExist.Error() + " by id: " + e.RuleID
+}
+
+// Unwrap 支持errors.Is(err, ErrRuleNotExist)
+func (e *RuleNotExistError) Unwrap() error {
+	return ErrRuleNotExist
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/valyala/fasthttp"
 	"github.com/wosai/deepmock/misc"
@@ -16,26 +17,43 @@ type (
 	// Rule 规则实体
 	Rule struct {
 		ID          string
+		Namespace   string // 规则所属的命名空间，规则只匹配该命名空间的请求
 		Path        string
 		Method      string
 		Variable    map[string]interface{}
 		Weight      map[string]WeightFactor
 		Regulations []*Regulation
+		Priority    int
+		Scenario    *Scenario
 		Version     int
+		MTime       time.Time // 最后修改时间，由存储库维护
+		Disabled    bool      // 禁用的规则不参与匹配，但仍保留在存储库中
+	}
+
+	// RuleChanges 自水位线之后变更的规则，用于增量同步
+	RuleChanges struct {
+		Updated   []*Rule   // 新增或修改的规则
+		Deleted   []string  // 被删除或禁用的规则ID
+		Watermark time.Time // 下一次增量同步使用的水位线
 	}
 
 	// Regulation 响应报文值对象
 	Regulation struct {
-		IsDefault bool      `json:"is_default,omitempty"`
-		Filter    *Filter   `json:"filter,omitempty"`
-		Template  *Template `json:"response,omitempty"`
+		IsDefault bool        `json:"is_default,omitempty"`
+		Filter    *Filter     `json:"filter,omitempty"`
+		Template  *Template   `json:"response,omitempty"`
+		Fault     FaultFactor `json:"fault,omitempty"`
+		Scenario  *Scenario   `json:"scenario,omitempty"`
 	}
 
-	// Filter 筛选规则值对象
+	// Filter 筛选规则值对象，Query、Header、Body与组合条件之间为“且”的关系
 	Filter struct {
 		Query  QueryFilterParams  `json:"query,omitempty"`
 		Header HeaderFilterParams `json:"header,omitempty"`
 		Body   BodyFilterParams   `json:"body,omitempty"`
+		AllOf  []*Filter          `json:"all_of,omitempty"`
+		AnyOf  []*Filter          `json:"any_of,omitempty"`
+		Not    *Filter            `json:"not,omitempty"`
 	}
 
 	// Template 模板值对象
@@ -47,6 +65,7 @@ type (
 		StatusCode     int               `json:"status_code,omitempty"`
 		Body           string            `json:"body,omitempty"`
 		B64EncodedBody string            `json:"b64encoded_body,omitempty"`
+		Delay          *Delay            `json:"delay,omitempty"`
 	}
 
 	// WeightFactor 权重因子值对象
@@ -77,18 +96,85 @@ func (f *Filter) Validate() error {
 	}
 
 	if f.Body != nil {
-		if _, ok := f.Body[ModeField]; !ok {
+		mode, ok := f.Body[ModeField]
+		if !ok {
 			return errors.New("missing mode in body filter")
 		}
+		if mode == FilterModeJSONPath {
+			if _, err := f.Body.To(); err != nil {
+				return err
+			}
+		}
 	}
-	return nil
+
+	for _, sub := range f.AllOf {
+		if sub == nil {
+			return errors.New("empty filter in all_of")
+		}
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, sub := range f.AnyOf {
+		if sub == nil {
+			return errors.New("empty filter in any_of")
+		}
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+	return f.Not.Validate()
+}
+
+// To 转换成FilterExecutor
+func (f *Filter) To() (*FilterExecutor, error) {
+	fe := new(FilterExecutor)
+	if f == nil {
+		return fe, nil
+	}
+
+	var err error
+	if fe.Query, err = f.Query.To(); err != nil {
+		return nil, err
+	}
+	if fe.Header, err = f.Header.To(); err != nil {
+		return nil, err
+	}
+	if fe.Body, err = f.Body.To(); err != nil {
+		return nil, err
+	}
+
+	for _, sub := range f.AllOf {
+		se, err := sub.To()
+		if err != nil {
+			return nil, err
+		}
+		fe.AllOf = append(fe.AllOf, se)
+	}
+	for _, sub := range f.AnyOf {
+		se, err := sub.To()
+		if err != nil {
+			return nil, err
+		}
+		fe.AnyOf = append(fe.AnyOf, se)
+	}
+	if f.Not != nil {
+		if fe.Not, err = f.Not.To(); err != nil {
+			return nil, err
+		}
+	}
+	return fe, nil
 }
 
 // Validate 校验函数
 func (r *Regulation) Validate() error {
-	if !r.IsDefault && r.Filter == nil {
+	requireState := r.Scenario != nil && r.Scenario.RequiredState != ""
+	if !r.IsDefault && r.Filter == nil && !requireState {
 		return errors.New("unreachable regulation")
 	}
+	if r.IsDefault && requireState {
+		return errors.New("default regulation cannot require scenario state")
+	}
 	if err := r.Filter.Validate(); err != nil {
 		return err
 	}
@@ -98,7 +184,10 @@ func (r *Regulation) Validate() error {
 	if r.Template.StatusCode == 0 {
 		r.Template.StatusCode = http.StatusOK
 	}
-	return nil
+	if err := r.Fault.Validate(); err != nil {
+		return err
+	}
+	return r.Template.Delay.Validate()
 }
 
 // To 转换成响应规则执行器
@@ -107,24 +196,13 @@ func (r *Regulation) To() (*RegulationExecutor, error) {
 
 	exec := &RegulationExecutor{
 		IsDefault: r.IsDefault,
-		Filter:    new(FilterExecutor),
 		Template:  new(TemplateExecutor),
+		Fault:     r.Fault.To(),
+		Scenario:  r.Scenario,
 	}
-	if r.Filter != nil {
-		exec.Filter.Query, err = r.Filter.Query.To()
-		if err != nil {
-			return nil, err
-		}
-
-		exec.Filter.Header, err = r.Filter.Header.To()
-		if err != nil {
-			return nil, err
-		}
-
-		exec.Filter.Body, err = r.Filter.Body.To()
-		if err != nil {
-			return nil, err
-		}
+	exec.Filter, err = r.Filter.To()
+	if err != nil {
+		return nil, err
 	}
 
 	exec.Template, err = r.Template.To()
@@ -137,9 +215,13 @@ func (r *Regulation) To() (*RegulationExecutor, error) {
 // Validate 校验Rule的有效性
 func (rule *Rule) Validate() error {
 	rule.Method = strings.ToUpper(rule.Method)
+	rule.Namespace = NormalizeNamespace(rule.Namespace)
+	if err := ValidateNamespace(rule.Namespace); err != nil {
+		return err
+	}
 	rule.SupplyID()
 
-	if rule.ID != "" && misc.GenID([]byte(rule.Path), []byte(rule.Method)) != rule.ID {
+	if rule.ID != "" && GenRuleID(rule.Namespace, rule.Path, rule.Method) != rule.ID {
 		return errors.New("invalid rule id")
 	}
 	if len(rule.Path) == 0 {
@@ -152,6 +234,10 @@ func (rule *Rule) Validate() error {
 		return errors.New("missing regulation")
 	}
 
+	if err := rule.Scenario.Validate(); err != nil {
+		return err
+	}
+
 	var d int
 	for _, reg := range rule.Regulations {
 		if reg.IsDefault {
@@ -160,6 +246,9 @@ func (rule *Rule) Validate() error {
 		if err := reg.Validate(); err != nil {
 			return err
 		}
+		if err := reg.Scenario.inherit(rule.Scenario).Validate(); err != nil {
+			return err
+		}
 	}
 	if d != 1 {
 		return errors.New("no default regulation or provided more than one")
@@ -173,12 +262,12 @@ func (rule *Rule) SupplyID() (string, bool) {
 		return rule.ID, false
 	}
 
-	rule.ID = misc.GenID([]byte(rule.Path), []byte(rule.Method))
+	rule.ID = GenRuleID(rule.Namespace, rule.Path, rule.Method)
 	return rule.ID, true
 }
 
-// Patch 更新对象
-func (rule *Rule) Patch(nr *Rule) error {
+// Patch 更新对象，priority为nil时不修改优先级，以便将优先级修改为0
+func (rule *Rule) Patch(nr *Rule, priority *int) error {
 	rule.Version++
 
 	// Variable
@@ -218,6 +307,16 @@ func (rule *Rule) Patch(nr *Rule) error {
 		rule.Regulations = nr.Regulations
 	}
 
+	// priority
+	if priority != nil {
+		rule.Priority = *priority
+	}
+
+	// scenario
+	if nr.Scenario != nil {
+		rule.Scenario = nr.Scenario
+	}
+
 	return rule.Validate()
 }
 
@@ -228,9 +327,17 @@ func (rule *Rule) Put(nr *Rule) error {
 	rule.Variable = nr.Variable
 	rule.Weight = nr.Weight
 	rule.Regulations = nr.Regulations
+	rule.Priority = nr.Priority
+	rule.Scenario = nr.Scenario
 	return rule.Validate()
 }
 
+// SetDisabled 禁用或启用规则
+func (rule *Rule) SetDisabled(disabled bool) {
+	rule.Version++
+	rule.Disabled = disabled
+}
+
 // To 转换成Executor实体
 func (rule *Rule) To() (*Executor, error) {
 	if err := rule.Validate(); err != nil {
@@ -239,15 +346,20 @@ func (rule *Rule) To() (*Executor, error) {
 	var err error
 	exec := &Executor{
 		ID:          rule.ID,
+		Namespace:   rule.Namespace,
 		Method:      []byte(rule.Method),
 		Variable:    rule.Variable,
 		Regulations: nil,
+		Priority:    rule.Priority,
+		Scenario:    rule.Scenario,
 		Version:     rule.Version,
+		MTime:       rule.MTime,
 	}
 	exec.Path, err = regexp.Compile(rule.Path)
 	if err != nil {
 		return nil, err
 	}
+	exec.literalPrefix, exec.isLiteral = exec.Path.LiteralPrefix()
 	exec.Weight = make(WeightPicker, len(rule.Weight))
 	for k, factor := range rule.Weight {
 		exec.Weight[k] = factor.To()
@@ -259,6 +371,7 @@ func (rule *Rule) To() (*Executor, error) {
 		if err != nil {
 			return nil, err
 		}
+		re.Scenario = regulation.Scenario.inherit(rule.Scenario)
 		exec.Regulations[index] = re
 	}
 	return exec, nil
@@ -372,11 +485,33 @@ func (bfp BodyFilterParams) To() (*BodyFilterExecutor, error) {
 				return nil, err
 			}
 			bfe.regular = reg
+
+		case FilterModeJSONPath:
+			jpm, err := newJSONPathMatcher(k, v)
+			if err != nil {
+				return nil, err
+			}
+			bfe.jsonPaths = append(bfe.jsonPaths, jpm)
 		}
 	}
 	return bfe, nil
 }
 
+// newJSONPathMatcher 构造JSONPath筛选条件，value形如/pattern/时作为正则表达式处理
+func newJSONPathMatcher(expr, value string) (*jsonPathMatcher, error) {
+	path, err := CompileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	jpm := &jsonPathMatcher{path: path, expected: value}
+	if len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		if jpm.regular, err = regexp.Compile(value[1 : len(value)-1]); err != nil {
+			return nil, err
+		}
+	}
+	return jpm, nil
+}
+
 // To 转换成TemplateExecutor
 func (tmp *Template) To() (*TemplateExecutor, error) {
 	te := &TemplateExecutor{
@@ -419,5 +554,11 @@ func (tmp *Template) To() (*TemplateExecutor, error) {
 		}
 		te.headerTemplate = tmpl
 	}
+
+	delay, err := tmp.Delay.To()
+	if err != nil {
+		return nil, err
+	}
+	te.delay = delay
 	return te, nil
 }
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRule_PatchPriority(t *testing.T) {
+	regulations := []*Regulation{{IsDefault: true, Template: &Template{}}}
+	rule := &Rule{Path: "/whoami", Method: "GET", Priority: 5, Regulations: regulations}
+	assert.NoError(t, rule.Validate())
+
+	// 未设置优先级时保持不变
+	assert.NoError(t, rule.Patch(&Rule{}, nil))
+	assert.Equal(t, 5, rule.Priority)
+
+	priority := 0
+	assert.NoError(t, rule.Patch(&Rule{}, &priority))
+	assert.Equal(t, 0, rule.Priority)
+	assert.Equal(t, 2, rule.Version)
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	// ScenarioStateStarted 场景的初始状态
+	ScenarioStateStarted = "Started"
+)
+
+type (
+	// Scenario 场景值对象，用于实现有状态的连续响应
+	Scenario struct {
+		Name          string `json:"name,omitempty"`
+		RequiredState string `json:"required_state,omitempty"` // 场景处于该状态时才生效
+		NewState      string `json:"new_state,omitempty"`      // 响应后场景切换到的状态
+	}
+
+	// ScenarioTransition 场景状态切换，Expected为空时不校验当前状态，State为空时只校验不切换
+	ScenarioTransition struct {
+		Name     string
+		Expected string
+		State    string
+	}
+)
+
+// Validate 校验函数
+func (s *Scenario) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Name == "" {
+		return errors.New("missing scenario name")
+	}
+	return nil
+}
+
+// inherit 返回继承了规则场景名称的场景对象
+func (s *Scenario) inherit(parent *Scenario) *Scenario {
+	if s == nil {
+		return nil
+	}
+	ns := *s
+	if ns.Name == "" && parent != nil {
+		ns.Name = parent.Name
+	}
+	return &ns
+}
+
+// satisfied 判断命名空间中场景的当前状态是否满足要求
+func (s *Scenario) satisfied(ctx context.Context, sr ScenarioRepository, namespace string) bool {
+	if s == nil || s.RequiredState == "" {
+		return true
+	}
+	if sr == nil {
+		return s.RequiredState == ScenarioStateStarted
+	}
+	return sr.GetState(ctx, namespace, s.Name) == s.RequiredState
+}
+
+// transition 合并场景状态切换，同一场景保留最先要求的状态及最后切换到的状态
+func (s *Scenario) transition(transitions []ScenarioTransition) []ScenarioTransition {
+	if s == nil || (s.RequiredState == "" && s.NewState == "") {
+		return transitions
+	}
+	for index := range transitions {
+		if transitions[index].Name != s.Name {
+			continue
+		}
+		if transitions[index].Expected == "" {
+			transitions[index].Expected = s.RequiredState
+		}
+		if s.NewState != "" {
+			transitions[index].State = s.NewState
+		}
+		return transitions
+	}
+	return append(transitions, ScenarioTransition{Name: s.Name, Expected: s.RequiredState, State: s.NewState})
+}
+
+// Available 规则级别的场景状态是否满足
+func (exe *Executor) Available(ctx context.Context, sr ScenarioRepository) bool {
+	return exe.Scenario.satisfied(ctx, sr, exe.Namespace)
+}
+
+// Transit 切换规则及报文规则上声明的场景状态，报文规则上的声明后生效；
+// 场景状态在匹配之后被其他请求修改、不再满足要求时不切换并返回false，调用方应重新匹配
+func (exe *Executor) Transit(ctx context.Context, sr ScenarioRepository, re *RegulationExecutor) bool {
+	if sr == nil {
+		return true
+	}
+	transitions := exe.Scenario.transition(nil)
+	if re != nil {
+		transitions = re.Scenario.transition(transitions)
+	}
+	if len(transitions) == 0 {
+		return true
+	}
+	return sr.CompareAndSet(ctx, exe.Namespace, transitions...)
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+// mapScenarioRepository 以"命名空间/场景名"为键记录场景状态
+type mapScenarioRepository map[string]string
+
+func (m mapScenarioRepository) GetState(_ context.Context, namespace, name string) string {
+	if state, ok := m[namespace+"/"+name]; ok {
+		return state
+	}
+	return ScenarioStateStarted
+}
+
+func (m mapScenarioRepository) CompareAndSet(ctx context.Context, namespace string, transitions ...ScenarioTransition) bool {
+	for _, transition := range transitions {
+		if transition.Expected != "" && m.GetState(ctx, namespace, transition.Name) != transition.Expected {
+			return false
+		}
+	}
+	for _, transition := range transitions {
+		if transition.State != "" {
+			m[namespace+"/"+transition.Name] = transition.State
+		}
+	}
+	return true
+}
+
+func (m mapScenarioRepository) ListStates(_ context.Context, namespace string) map[string]string {
+	states := map[string]string{}
+	for key, state := range m {
+		if name := strings.TrimPrefix(key, namespace+"/"); name != key {
+			states[name] = state
+		}
+	}
+	return states
+}
+
+func (m mapScenarioRepository) Reset(_ context.Context, namespace string, names ...string) {
+	for _, name := range names {
+		delete(m, namespace+"/"+name)
+	}
+}
+
+func TestExecutor_FindRegulationExecutorWithScenario(t *testing.T) {
+	rule := &Rule{
+		Path:     "/pay/query",
+		Method:   "GET",
+		Scenario: &Scenario{Name: "polling"},
+		Regulations: []*Regulation{
+			{
+				Scenario: &Scenario{RequiredState: ScenarioStateStarted, NewState: "second"},
+				Template: &Template{Body: "PROCESSING"},
+			},
+			{
+				Scenario: &Scenario{RequiredState: "second", NewState: "third"},
+				Template: &Template{Body: "PROCESSING"},
+			},
+			{
+				IsDefault: true,
+				Template:  &Template{Body: "SUCCESS"},
+			},
+		},
+	}
+	executor, err := rule.To()
+	assert.NoError(t, err)
+
+	sr := mapScenarioRepository{}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	var bodies []string
+	for i := 0; i < 4; i++ {
+		re := executor.FindRegulationExecutor(context.TODO(), req, sr)
+		bodies = append(bodies, string(re.Template.body))
+		assert.True(t, executor.Transit(context.TODO(), sr, re))
+	}
+	assert.Equal(t, []string{"PROCESSING", "PROCESSING", "SUCCESS", "SUCCESS"}, bodies)
+	assert.Equal(t, "third", sr[DefaultNamespace+"/polling"])
+
+	sr.Reset(context.TODO(), DefaultNamespace, "polling")
+	assert.Equal(t, "PROCESSING", string(executor.FindRegulationExecutor(context.TODO(), req, sr).Template.body))
+}
+
+func TestExecutor_TransitConcurrently(t *testing.T) {
+	rule := &Rule{
+		Path:     "/pay",
+		Method:   "POST",
+		Scenario: &Scenario{Name: "payment"},
+		Regulations: []*Regulation{
+			{Scenario: &Scenario{RequiredState: ScenarioStateStarted, NewState: "paid"}, Template: &Template{Body: "SUCCESS"}},
+			{IsDefault: true, Template: &Template{Body: "DUPLICATED"}},
+		},
+	}
+	executor, err := rule.To()
+	assert.NoError(t, err)
+
+	sr := mapScenarioRepository{}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	// 两个请求都在状态切换前完成匹配，只有一个可以切换成功
+	first := executor.FindRegulationExecutor(context.TODO(), req, sr)
+	second := executor.FindRegulationExecutor(context.TODO(), req, sr)
+	assert.Equal(t, first, second)
+	assert.True(t, executor.Transit(context.TODO(), sr, first))
+	assert.False(t, executor.Transit(context.TODO(), sr, second))
+	assert.Equal(t, "paid", sr[DefaultNamespace+"/payment"])
+
+	retry := executor.FindRegulationExecutor(context.TODO(), req, sr)
+	assert.Equal(t, "DUPLICATED", string(retry.Template.body))
+	assert.True(t, executor.Transit(context.TODO(), sr, retry))
+}
+
+func TestScenario_Validate(t *testing.T) {
+	rule := &Rule{
+		Path:   "/pay/query",
+		Method: "GET",
+		Regulations: []*Regulation{
+			{IsDefault: true, Scenario: &Scenario{NewState: "paid"}, Template: &Template{}},
+		},
+	}
+	assert.Error(t, rule.Validate())
+
+	rule.Scenario = &Scenario{Name: "payment"}
+	assert.NoError(t, rule.Validate())
+
+	rule.Regulations[0].Scenario.RequiredState = "created"
+	assert.Error(t, rule.Validate())
+}
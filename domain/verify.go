@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// RequestPattern 请求匹配模式值对象，用于校验请求日志中的请求
+	RequestPattern struct {
+		Method string
+		Path   string // 正则表达式
+		Filter *Filter
+	}
+
+	// RequestMatcher 请求匹配执行器
+	RequestMatcher struct {
+		method string
+		path   *regexp.Regexp
+		filter *FilterExecutor
+	}
+
+	// CountConstraint 请求次数约束值对象，均未设置时表示至少一次
+	CountConstraint struct {
+		Exactly *int
+		AtLeast *int
+		AtMost  *int
+	}
+)
+
+// To 转换成RequestMatcher，method与规则匹配一致不区分大小写
+func (rp *RequestPattern) To() (*RequestMatcher, error) {
+	if err := rp.Filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	rm := &RequestMatcher{method: strings.ToUpper(rp.Method)}
+	if rp.Path != "" {
+		re, err := regexp.Compile(rp.Path)
+		if err != nil {
+			return nil, err
+		}
+		rm.path = re
+	}
+	filter, err := rp.Filter.To()
+	if err != nil {
+		return nil, err
+	}
+	rm.filter = filter
+	return rm, nil
+}
+
+// Mismatches 返回请求日志未满足匹配模式的原因，全部满足时返回空
+func (rm *RequestMatcher) Mismatches(entry *JournalEntry) []string {
+	var reasons []string
+	if rm.method != "" && rm.method != entry.Method {
+		reasons = append(reasons, fmt.Sprintf("method expected %q got %q", rm.method, entry.Method))
+	}
+	if rm.path != nil && !rm.path.MatchString(entry.Path) {
+		reasons = append(reasons, fmt.Sprintf("path %q does not match /%s/", entry.Path, rm.path))
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	entry.CopyRequestTo(req)
+	return append(reasons, rm.filter.Mismatches(req)...)
+}
+
+// CopyRequestTo 将记录的请求还原到req中
+func (je *JournalEntry) CopyRequestTo(req *fasthttp.Request) {
+	req.Reset()
+	for k, v := range je.Header {
+		req.Header.Set(k, v)
+	}
+	req.Header.SetMethod(je.Method)
+	uri := je.Path
+	if je.Query != "" {
+		uri += "?" + je.Query
+	}
+	req.SetRequestURI(uri)
+	req.SetBody(je.Body)
+}
+
+// Validate 校验函数
+func (cc CountConstraint) Validate() error {
+	for _, n := range []*int{cc.Exactly, cc.AtLeast, cc.AtMost} {
+		if n != nil && *n < 0 {
+			return errors.New("negative count constraint")
+		}
+	}
+	if cc.Exactly != nil && (cc.AtLeast != nil || cc.AtMost != nil) {
+		return errors.New("exactly cannot be used with at_least or at_most")
+	}
+	if cc.AtLeast != nil && cc.AtMost != nil && *cc.AtLeast > *cc.AtMost {
+		return errors.New("at_least is greater than at_most")
+	}
+	return nil
+}
+
+// Satisfied 判断请求次数是否满足约束
+func (cc CountConstraint) Satisfied(n int) bool {
+	switch {
+	case cc.Exactly != nil:
+		return n == *cc.Exactly
+	case cc.AtLeast == nil && cc.AtMost == nil:
+		return n >= 1
+	}
+	if cc.AtLeast != nil && n < *cc.AtLeast {
+		return false
+	}
+	if cc.AtMost != nil && n > *cc.AtMost {
+		return false
+	}
+	return true
+}
+
+// String 约束的可读描述
+func (cc CountConstraint) String() string {
+	switch {
+	case cc.Exactly != nil:
+		return "exactly " + strconv.Itoa(*cc.Exactly)
+	case cc.AtLeast != nil && cc.AtMost != nil:
+		return "between " + strconv.Itoa(*cc.AtLeast) + " and " + strconv.Itoa(*cc.AtMost)
+	case cc.AtMost != nil:
+		return "at most " + strconv.Itoa(*cc.AtMost)
+	case cc.AtLeast != nil:
+		return "at least " + strconv.Itoa(*cc.AtLeast)
+	default:
+		return "at least 1"
+	}
+}
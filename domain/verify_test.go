@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMatcher_Mismatches(t *testing.T) {
+	pattern := &RequestPattern{
+		Method: "POST",
+		Path:   "^/pay/notify$",
+		Filter: &Filter{
+			Header: HeaderFilterParams{ModeField: FilterModeExact, "X-Sign": "abc"},
+			Query:  QueryFilterParams{ModeField: FilterModeRegular, "sn": `^\d+$`},
+			Body:   BodyFilterParams{ModeField: FilterModeJSONPath, "$.out_trade_no": "T001"},
+		},
+	}
+	matcher, err := pattern.To()
+	assert.NoError(t, err)
+
+	entry := &JournalEntry{
+		Method: "POST",
+		Path:   "/pay/notify",
+		Query:  "sn=123",
+		Header: map[string]string{"X-Sign": "abc", "Content-Type": "application/json"},
+		Body:   []byte(`{"out_trade_no": "T001"}`),
+	}
+	assert.Empty(t, matcher.Mismatches(entry))
+
+	entry = &JournalEntry{
+		Method: "GET",
+		Path:   "/pay/notify",
+		Query:  "sn=abc",
+		Header: map[string]string{"X-Sign": "xyz", "Content-Type": "application/json"},
+		Body:   []byte(`{"out_trade_no": "T002"}`),
+	}
+	assert.Equal(t, []string{
+		`method expected "POST" got "GET"`,
+		`header X-Sign expected "abc" got "xyz"`,
+		`query sn "abc" does not match /^\d+$/`,
+		`body $.out_trade_no expected "T001" got ["T002"]`,
+	}, matcher.Mismatches(entry))
+
+	// method不区分大小写
+	matcher, err = (&RequestPattern{Method: "post"}).To()
+	assert.NoError(t, err)
+	assert.Empty(t, matcher.Mismatches(&JournalEntry{Method: "POST", Path: "/pay/notify"}))
+	assert.Equal(t, []string{`method expected "POST" got "GET"`}, matcher.Mismatches(&JournalEntry{Method: "GET", Path: "/pay/notify"}))
+}
+
+func TestFilterExecutor_Mismatches(t *testing.T) {
+	filter := &Filter{
+		AnyOf: []*Filter{
+			{Body: BodyFilterParams{ModeField: FilterModeKeyword, FilterModeKeyword: "refund"}},
+			{Header: HeaderFilterParams{ModeField: FilterModeKeyword, "X-Biz": "refund"}},
+		},
+		Not: &Filter{Query: QueryFilterParams{ModeField: FilterModeExact, "env": "prod"}},
+	}
+	fe, err := filter.To()
+	assert.NoError(t, err)
+
+	matcher := &RequestMatcher{filter: fe}
+	entry := &JournalEntry{Method: "POST", Path: "/pay", Query: "env=prod", Body: []byte("pay")}
+	assert.Equal(t, []string{
+		"any_of: no sub filter matched",
+		`any_of[0]: body keyword "refund" absent`,
+		"not: sub filter matched",
+	}, matcher.Mismatches(entry))
+}
+
+func TestCountConstraint(t *testing.T) {
+	one, two := 1, 2
+
+	cc := CountConstraint{}
+	assert.NoError(t, cc.Validate())
+	assert.False(t, cc.Satisfied(0))
+	assert.True(t, cc.Satisfied(3))
+	assert.Equal(t, "at least 1", cc.String())
+
+	cc = CountConstraint{Exactly: &one}
+	assert.True(t, cc.Satisfied(1))
+	assert.False(t, cc.Satisfied(2))
+
+	cc = CountConstraint{AtLeast: &one, AtMost: &two}
+	assert.True(t, cc.Satisfied(2))
+	assert.False(t, cc.Satisfied(3))
+	assert.Equal(t, "between 1 and 2", cc.String())
+
+	assert.Error(t, CountConstraint{AtLeast: &two, AtMost: &one}.Validate())
+	assert.Error(t, CountConstraint{Exactly: &one, AtMost: &two}.Validate())
+}
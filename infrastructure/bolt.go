@@ -0,0 +1,427 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// StorageDriverBolt 规则存储在嵌入式BoltDB文件中
+const StorageDriverBolt = "bolt"
+
+type (
+	// BoltRuleRepository RuleRepository的BoltDB存储实现，记录的含义与db.sql一致，适合单机持久化
+	BoltRuleRepository struct {
+		db *bolt.DB
+	}
+)
+
+var (
+	ruleBucket          = []byte("rule")
+	ruleAPIBucket       = []byte("rule_api")       // path与method的唯一索引，value为规则ID
+	ruleTombstoneBucket = []byte("rule_tombstone") // 删除记录，value为删除时间
+	ruleHistoryBucket   = []byte("rule_history")   // 历史版本，每个规则一个子bucket，key为大端序的版本号
+)
+
+// NewBoltRuleRepository 工厂函数，数据库文件不存在时自动创建
+func NewBoltRuleRepository(path string) (*BoltRuleRepository, error) {
+	if path == "" {
+		return nil, errors.New("missing path of bolt database")
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{ruleBucket, ruleAPIBucket, ruleTombstoneBucket, ruleHistoryBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltRuleRepository{db: db}, nil
+}
+
+// Close 关闭数据库
+func (br *BoltRuleRepository) Close() error {
+	return br.db.Close()
+}
+
+func (br *BoltRuleRepository) get(tx *bolt.Tx, rid string) (*types.RuleDO, error) {
+	data := tx.Bucket(ruleBucket).Get([]byte(rid))
+	if data == nil {
+		return nil, nil
+	}
+	record := new(ruleRecord)
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record.dataObject(), nil
+}
+
+func (br *BoltRuleRepository) put(tx *bolt.Tx, do *types.RuleDO) error {
+	data, err := json.Marshal(newRuleRecord(do))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(ruleBucket).Put([]byte(do.ID), data)
+}
+
+// insert 插入记录，违反唯一约束时返回错误
+func (br *BoltRuleRepository) insert(tx *bolt.Tx, do *types.RuleDO) error {
+	if tx.Bucket(ruleBucket).Get([]byte(do.ID)) != nil {
+		return errors.New("duplicate rule id: " + do.ID)
+	}
+	key := []byte(apiKey(do.Namespace, do.Path, do.Method))
+	apis := tx.Bucket(ruleAPIBucket)
+	if apis.Get(key) != nil {
+		return errors.New("duplicate rule api: " + do.Method + " " + do.Path)
+	}
+	if err := apis.Put(key, []byte(do.ID)); err != nil {
+		return err
+	}
+	return br.put(tx, do)
+}
+
+func (br *BoltRuleRepository) remove(tx *bolt.Tx, do *types.RuleDO) error {
+	if err := tx.Bucket(ruleAPIBucket).Delete([]byte(apiKey(do.Namespace, do.Path, do.Method))); err != nil {
+		return err
+	}
+	return tx.Bucket(ruleBucket).Delete([]byte(do.ID))
+}
+
+// CreateRule 插入新纪录
+func (br *BoltRuleRepository) CreateRule(_ context.Context, rule *domain.Rule) error {
+	do, err := convertRuleEntity(rule)
+	if err != nil {
+		return err
+	}
+	return br.db.Update(func(tx *bolt.Tx) error {
+		do.CTime = time.Now() // 写事务串行执行，在事务内设置时间保证修改时间与增量同步的水位线有序
+		do.MTime = do.CTime
+		return br.insert(tx, do)
+	})
+}
+
+// UpdateRule 更新记录，与MySQL实现一致，版本号不匹配或规则不存在时返回*domain.VersionConflictError
+func (br *BoltRuleRepository) UpdateRule(_ context.Context, rule *domain.Rule) error {
+	do, err := convertRuleEntity(rule)
+	if err != nil {
+		return err
+	}
+	return br.db.Update(func(tx *bolt.Tx) error {
+		old, err := br.get(tx, do.ID)
+		if err != nil {
+			return err
+		}
+		if old == nil || old.Version != do.Version-1 {
+			return &domain.VersionConflictError{RuleID: do.ID, Expected: do.Version - 1}
+		}
+		old.Variable = do.Variable
+		old.Weight = do.Weight
+		old.Responses = do.Responses
+		old.Priority = do.Priority
+		old.Scenario = do.Scenario
+		old.Version = do.Version
+		old.Disabled = do.Disabled
+		old.MTime = time.Now()
+		return br.put(tx, old)
+	})
+}
+
+// GetRuleByID 获取记录
+func (br *BoltRuleRepository) GetRuleByID(_ context.Context, rid string) (*domain.Rule, error) {
+	var do *types.RuleDO
+	err := br.db.View(func(tx *bolt.Tx) error {
+		var err error
+		do, err = br.get(tx, rid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if do == nil {
+		return nil, &domain.RuleNotExistError{RuleID: rid}
+	}
+	return convertRuleDO(do)
+}
+
+// DeleteRule 删除记录，同时写入删除记录用于增量同步
+func (br *BoltRuleRepository) DeleteRule(_ context.Context, rid string, version int) error {
+	return br.db.Update(func(tx *bolt.Tx) error {
+		do, err := br.get(tx, rid)
+		if err != nil {
+			return err
+		}
+		if version != domain.AnyVersion && (do == nil || do.Version != version) {
+			return &domain.VersionConflictError{RuleID: rid, Expected: version}
+		}
+		if do == nil {
+			return nil
+		}
+		if err = br.remove(tx, do); err != nil {
+			return err
+		}
+		return br.bury(tx, time.Now(), rid)
+	})
+}
+
+// bury 写入删除记录，并清理过期的删除记录
+func (br *BoltRuleRepository) bury(tx *bolt.Tx, now time.Time, rids ...string) error {
+	tombstones := tx.Bucket(ruleTombstoneBucket)
+	var expired [][]byte
+	err := tombstones.ForEach(func(id, data []byte) error {
+		var dtime time.Time
+		if err := dtime.UnmarshalText(data); err != nil || now.Sub(dtime) > tombstoneRetention {
+			expired = append(expired, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range expired {
+		if err = tombstones.Delete(id); err != nil {
+			return err
+		}
+	}
+	data, _ := now.MarshalText()
+	for _, rid := range rids {
+		if err = tombstones.Put([]byte(rid), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export 导出记录，包括禁用的规则，按规则ID排序
+func (br *BoltRuleRepository) Export(_ context.Context) ([]*domain.Rule, error) {
+	entities := make([]*domain.Rule, 0)
+	err := br.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(ruleBucket).ForEach(func(_, data []byte) error {
+			record := new(ruleRecord)
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			entity, err := convertRuleDO(record.dataObject())
+			if err != nil {
+				return err
+			}
+			entities = append(entities, entity)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// ExportChanges 导出修改时间不早于水位线的规则及删除记录，禁用的规则视为删除
+func (br *BoltRuleRepository) ExportChanges(_ context.Context, since time.Time) (*domain.RuleChanges, error) {
+	changes := &domain.RuleChanges{Updated: make([]*domain.Rule, 0), Watermark: since}
+	err := br.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(ruleBucket).ForEach(func(_, data []byte) error {
+			record := new(ruleRecord)
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			if record.MTime.Before(since) {
+				return nil
+			}
+			if record.MTime.After(changes.Watermark) {
+				changes.Watermark = record.MTime
+			}
+			if record.Disabled {
+				changes.Deleted = append(changes.Deleted, record.ID)
+				return nil
+			}
+			entity, err := convertRuleDO(record.dataObject())
+			if err != nil {
+				return err
+			}
+			changes.Updated = append(changes.Updated, entity)
+			return nil
+		})
+		if err != nil || since.IsZero() {
+			return err
+		}
+
+		return tx.Bucket(ruleTombstoneBucket).ForEach(func(id, data []byte) error {
+			var dtime time.Time
+			if err := dtime.UnmarshalText(data); err != nil {
+				return err
+			}
+			if dtime.Before(since) {
+				return nil
+			}
+			if dtime.After(changes.Watermark) {
+				changes.Watermark = dtime
+			}
+			changes.Deleted = append(changes.Deleted, string(id))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Import 导入记录，在同一个事务中覆盖ID相同的规则，任意规则失败时整体回滚
+func (br *BoltRuleRepository) Import(ctx context.Context, rules ...*domain.Rule) error {
+	return br.Replace(ctx, rules, nil, nil)
+}
+
+// Replace 在同一个事务中校验版本号、删除指定的规则并导入记录，任意规则失败时整体回滚
+func (br *BoltRuleRepository) Replace(_ context.Context, rules []*domain.Rule, deleted []string, expected map[string]int) error {
+	dataObjects := make([]*types.RuleDO, len(rules))
+	for index, rule := range rules {
+		do, err := convertRuleEntity(rule)
+		if err != nil {
+			return err
+		}
+		dataObjects[index] = do
+	}
+
+	return br.db.Update(func(tx *bolt.Tx) error {
+		for rid, version := range expected {
+			old, err := br.get(tx, rid)
+			if err != nil {
+				return err
+			}
+			if old == nil || old.Version != version {
+				return &domain.VersionConflictError{RuleID: rid, Expected: version}
+			}
+		}
+		now := time.Now()
+		for _, do := range dataObjects {
+			do.CTime, do.MTime = now, now
+		}
+		var buried []string
+		for _, rid := range deleted {
+			old, err := br.get(tx, rid)
+			if err != nil {
+				return err
+			}
+			if old != nil {
+				if err = br.remove(tx, old); err != nil {
+					return err
+				}
+				buried = append(buried, rid)
+			}
+		}
+		if len(buried) > 0 {
+			if err := br.bury(tx, now, buried...); err != nil {
+				return err
+			}
+		}
+		// 清空存在的记录
+		for _, do := range dataObjects {
+			old, err := br.get(tx, do.ID)
+			if err != nil {
+				return err
+			}
+			if old != nil {
+				if err = br.remove(tx, old); err != nil {
+					return err
+				}
+			}
+		}
+		for _, do := range dataObjects {
+			if err := br.insert(tx, do); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveRevision 保存历史版本，版本号为规则子bucket中的下一个序号
+func (br *BoltRuleRepository) SaveRevision(_ context.Context, rev *domain.RuleRevision) error {
+	return br.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(ruleHistoryBucket).CreateBucketIfNotExists([]byte(rev.RuleID))
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		rev.Revision = int(seq)
+		rev.CTime = time.Now()
+		record, err := newRevisionRecord(rev)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(revisionKey(rev.Revision), data)
+	})
+}
+
+func revisionKey(revision int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(revision))
+	return key
+}
+
+// ListRevisions 按版本号升序返回规则的所有历史版本
+func (br *BoltRuleRepository) ListRevisions(_ context.Context, rid string) ([]*domain.RuleRevision, error) {
+	revisions := make([]*domain.RuleRevision, 0)
+	err := br.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ruleHistoryBucket).Bucket([]byte(rid))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, data []byte) error {
+			record := new(revisionRecord)
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			rev, err := record.entity()
+			if err != nil {
+				return err
+			}
+			revisions = append(revisions, rev)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision 获取指定的历史版本
+func (br *BoltRuleRepository) GetRevision(_ context.Context, rid string, revision int) (*domain.RuleRevision, error) {
+	record := new(revisionRecord)
+	err := br.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(ruleHistoryBucket).Bucket([]byte(rid))
+		if bucket == nil || revision < 1 {
+			return revisionNotFound(rid, revision)
+		}
+		data := bucket.Get(revisionKey(revision))
+		if data == nil {
+			return revisionNotFound(rid, revision)
+		}
+		return json.Unmarshal(data, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record.entity()
+}
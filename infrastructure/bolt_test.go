@@ -0,0 +1,47 @@
+package infrastructure
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltRuleRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deepmock.db")
+	repo, err := NewBoltRuleRepository(path)
+	assert.NoError(t, err)
+	testRuleRepository(t, repo)
+	ctx := context.TODO()
+
+	// 删除及禁用的规则都在增量导出中
+	rule, err := repo.GetRuleByID(ctx, "d")
+	assert.NoError(t, err)
+	since := rule.MTime
+	rule.SetDisabled(true)
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	changes, err := repo.ExportChanges(ctx, since)
+	assert.NoError(t, err)
+	assert.Subset(t, changes.Deleted, []string{"c", "d", "e"})
+	assert.Empty(t, changes.Updated)
+	assert.NoError(t, repo.Close())
+
+	// 重新打开后数据仍然存在
+	repo, err = NewBoltRuleRepository(path)
+	assert.NoError(t, err)
+	defer repo.Close()
+	rules, err := repo.Export(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, "d", rules[0].ID)
+		assert.True(t, rules[0].Disabled)
+	}
+}
+
+func TestBoltRuleRepository_Namespace(t *testing.T) {
+	repo, err := NewBoltRuleRepository(filepath.Join(t.TempDir(), "deepmock.db"))
+	assert.NoError(t, err)
+	defer repo.Close()
+	testRuleRepositoryNamespace(t, repo)
+}
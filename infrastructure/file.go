@@ -0,0 +1,614 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// StorageDriverFile 规则以YAML/JSON文件的形式存储在目录中
+const StorageDriverFile = "file"
+
+type (
+	// RuleConverter 规则文件中的types.RuleDTO与规则实体之间的转换
+	RuleConverter interface {
+		ToEntity(*types.RuleDTO) *domain.Rule
+		ToDTO(*domain.Rule) *types.RuleDTO
+	}
+
+	// FileRuleRepository RuleRepository的文件存储实现，目录中每个文件包含一个规则或规则列表。
+	// 每次Export时检查文件变化，配合同步任务实现热加载；无效的文件会被跳过，不影响其他文件
+	FileRuleRepository struct {
+		dir       string
+		readOnly  bool
+		converter RuleConverter
+		files     map[string]*ruleFile // key为文件路径
+		rules     map[string]*ruleFile // key为规则ID
+		apis      map[string]string    // path与method的唯一索引，value为规则ID
+		mu        sync.Mutex
+	}
+
+	// fileRule 规则文件中的规则，在规则报文之外保存版本号，使版本号及ETag在重启后保持不变
+	fileRule struct {
+		types.RuleDTO
+		Version int `json:"version,omitempty"`
+	}
+
+	ruleFile struct {
+		path     string
+		list     bool // 文件内容为规则列表
+		modTime  time.Time
+		size     int64
+		rules    []*domain.Rule
+		parseErr error
+		err      error // 解析错误或者与其他文件冲突
+	}
+)
+
+var (
+	// ErrReadOnlyRuleStore 只读模式下不允许修改规则
+	ErrReadOnlyRuleStore = errors.New("rule store is read-only")
+)
+
+// NewFileRuleRepository 工厂函数，目录不存在时会自动创建
+func NewFileRuleRepository(dir string, readOnly bool, converter RuleConverter) (*FileRuleRepository, error) {
+	if dir == "" {
+		return nil, errors.New("missing directory of rule files")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	fr := &FileRuleRepository{
+		dir:       dir,
+		readOnly:  readOnly,
+		converter: converter,
+		files:     map[string]*ruleFile{},
+		rules:     map[string]*ruleFile{},
+		apis:      map[string]string{},
+	}
+	if err := fr.refresh(); err != nil {
+		return nil, err
+	}
+	return fr, nil
+}
+
+func isRuleFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func isYAMLFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) != ".json"
+}
+
+// yamlToJSON YAML解析出的map的key为interface{}，需要转换后才能编码成JSON
+func yamlToJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[fmt.Sprint(k)] = yamlToJSON(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range val {
+			val[i] = yamlToJSON(e)
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// parseRuleFile 解析规则文件，文件中任意规则无效时整个文件视为无效
+func (fr *FileRuleRepository) parseRuleFile(path string) *ruleFile {
+	f := &ruleFile{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		f.parseErr = err
+		return f
+	}
+	if isYAMLFile(path) {
+		var v interface{}
+		if err = yaml.Unmarshal(data, &v); err != nil {
+			f.parseErr = err
+			return f
+		}
+		if data, err = json.Marshal(yamlToJSON(v)); err != nil {
+			f.parseErr = err
+			return f
+		}
+	}
+
+	var items []*fileRule
+	data = bytes.TrimSpace(data)
+	if f.list = bytes.HasPrefix(data, []byte("[")); f.list {
+		err = json.Unmarshal(data, &items)
+	} else {
+		item := new(fileRule)
+		err = json.Unmarshal(data, item)
+		items = []*fileRule{item}
+	}
+	if err != nil {
+		f.parseErr = err
+		return f
+	}
+
+	for index, item := range items {
+		if item == nil {
+			f.parseErr = errors.New("null rule at index " + strconv.Itoa(index))
+			return f
+		}
+		rule := fr.converter.ToEntity(&item.RuleDTO)
+		if _, err = rule.To(); err != nil {
+			f.parseErr = errors.New("invalid rule at index " + strconv.Itoa(index) + ": " + err.Error())
+			return f
+		}
+		rule.Version = item.Version
+		f.rules = append(f.rules, rule)
+	}
+	return f
+}
+
+// refresh 重新解析有变化的文件，调用方需持有锁
+func (fr *FileRuleRepository) refresh() error {
+	infos := map[string]fs.FileInfo{}
+	err := filepath.WalkDir(fr.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != fr.dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || !isRuleFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos[path] = info
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changed := len(infos) != len(fr.files)
+	files := make(map[string]*ruleFile, len(infos))
+	for path, info := range infos {
+		if f, exists := fr.files[path]; exists && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+			files[path] = f
+			continue
+		}
+		f := fr.parseRuleFile(path)
+		f.modTime, f.size = info.ModTime(), info.Size()
+		for _, rule := range f.rules {
+			rule.MTime = f.modTime
+		}
+		files[path] = f
+		changed = true
+	}
+	if changed {
+		fr.rebuild(files)
+	}
+	return nil
+}
+
+// rebuild 按文件路径顺序重建索引，与已加载文件冲突的文件视为无效；
+// 文件中的版本号未增加而规则内容有变化时（如手工修改文件），在已加载的版本号上加1
+func (fr *FileRuleRepository) rebuild(files map[string]*ruleFile) {
+	previous := map[string]*domain.Rule{}
+	for rid, f := range fr.rules {
+		for _, rule := range f.rules {
+			if rule.ID == rid {
+				previous[rid] = rule
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	rules := map[string]*ruleFile{}
+	apis := map[string]string{}
+	var invalid int
+	for _, path := range paths {
+		f := files[path]
+		f.err = f.parseErr
+		if f.err == nil {
+			f.err = fr.checkConflict(f, rules, apis)
+		}
+		if f.err != nil {
+			invalid++
+			misc.Logger.Error("skipped invalid rule file", zap.String("file", path), zap.Error(f.err))
+			continue
+		}
+		for _, rule := range f.rules {
+			rules[rule.ID] = f
+			apis[apiKey(rule.Namespace, rule.Path, rule.Method)] = rule.ID
+			if old, exists := previous[rule.ID]; exists && rule.Version <= old.Version {
+				rule.Version = old.Version
+				if !fr.sameContent(old, rule) {
+					rule.Version++
+				}
+			}
+		}
+	}
+	fr.files, fr.rules, fr.apis = files, rules, apis
+	invalidRuleFiles.Set(float64(invalid))
+}
+
+func (fr *FileRuleRepository) checkConflict(f *ruleFile, rules map[string]*ruleFile, apis map[string]string) error {
+	seen := map[string]struct{}{}
+	for _, rule := range f.rules {
+		if other, exists := rules[rule.ID]; exists {
+			return errors.New("duplicate rule id " + rule.ID + " in " + other.path)
+		}
+		if _, exists := seen[rule.ID]; exists {
+			return errors.New("duplicate rule id " + rule.ID)
+		}
+		if _, exists := apis[apiKey(rule.Namespace, rule.Path, rule.Method)]; exists {
+			return errors.New("duplicate rule api: " + rule.Method + " " + rule.Path)
+		}
+		seen[rule.ID] = struct{}{}
+	}
+	return nil
+}
+
+func (fr *FileRuleRepository) sameContent(a, b *domain.Rule) bool {
+	left, err := json.Marshal(fr.converter.ToDTO(a))
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(fr.converter.ToDTO(b))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(left, right)
+}
+
+// copyRule 通过规则报文深拷贝规则实体，避免调用方修改已加载的规则
+func (fr *FileRuleRepository) copyRule(rule *domain.Rule) *domain.Rule {
+	c := fr.converter.ToEntity(fr.converter.ToDTO(rule))
+	c.Version, c.MTime = rule.Version, rule.MTime
+	return c
+}
+
+func (fr *FileRuleRepository) newFileRule(rule *domain.Rule) *fileRule {
+	return &fileRule{RuleDTO: *fr.converter.ToDTO(rule), Version: rule.Version}
+}
+
+// encode 按文件原有的格式编码规则，同时保存版本号
+func (fr *FileRuleRepository) encode(f *ruleFile) ([]byte, error) {
+	var v interface{}
+	if f.list {
+		items := make([]*fileRule, len(f.rules))
+		for i, rule := range f.rules {
+			items[i] = fr.newFileRule(rule)
+		}
+		v = items
+	} else {
+		v = fr.newFileRule(f.rules[0])
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil || !isYAMLFile(f.path) {
+		return append(data, '\n'), err
+	}
+
+	// JSON是YAML的子集，借助MapSlice保留字段顺序
+	if f.list {
+		var items []yaml.MapSlice
+		if err = yaml.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(items)
+	}
+	var item yaml.MapSlice
+	if err = yaml.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(item)
+}
+
+// save 将规则写回文件，文件中没有规则时删除文件，调用方需持有锁
+func (fr *FileRuleRepository) save(f *ruleFile) error {
+	if len(f.rules) == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		delete(fr.files, f.path)
+		return nil
+	}
+
+	data, err := fr.encode(f)
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(f.path, data); err != nil {
+		return err
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+	for _, rule := range f.rules {
+		rule.MTime = f.modTime
+	}
+	fr.files[f.path] = f
+	return nil
+}
+
+// replace 替换文件中的规则并写回，失败时恢复，调用方需持有锁
+func (fr *FileRuleRepository) replace(f *ruleFile, rule *domain.Rule) error {
+	rules := make([]*domain.Rule, len(f.rules))
+	for i, r := range f.rules {
+		rules[i] = r
+		if r.ID == rule.ID {
+			rules[i] = rule
+		}
+	}
+	old := f.rules
+	f.rules = rules
+	if err := fr.save(f); err != nil {
+		f.rules = old
+		return err
+	}
+	return nil
+}
+
+// create 为规则创建新文件，调用方需持有锁
+func (fr *FileRuleRepository) create(rule *domain.Rule) error {
+	f := &ruleFile{path: filepath.Join(fr.dir, rule.ID+".yaml"), rules: []*domain.Rule{rule}}
+	if _, err := os.Stat(f.path); err == nil {
+		return errors.New("rule file already exists: " + f.path)
+	}
+	if err := fr.save(f); err != nil {
+		return err
+	}
+	fr.rules[rule.ID] = f
+	fr.apis[apiKey(rule.Namespace, rule.Path, rule.Method)] = rule.ID
+	return nil
+}
+
+func (fr *FileRuleRepository) lookup(rid string) (*domain.Rule, *ruleFile) {
+	f, exists := fr.rules[rid]
+	if !exists {
+		return nil, nil
+	}
+	for _, rule := range f.rules {
+		if rule.ID == rid {
+			return rule, f
+		}
+	}
+	return nil, nil
+}
+
+// CreateRule 为规则创建新文件
+func (fr *FileRuleRepository) CreateRule(_ context.Context, rule *domain.Rule) error {
+	if fr.readOnly {
+		return ErrReadOnlyRuleStore
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.refresh(); err != nil {
+		return err
+	}
+
+	if _, exists := fr.rules[rule.ID]; exists {
+		return errors.New("duplicate rule id: " + rule.ID)
+	}
+	if _, exists := fr.apis[apiKey(rule.Namespace, rule.Path, rule.Method)]; exists {
+		return errors.New("duplicate rule api: " + rule.Method + " " + rule.Path)
+	}
+	return fr.create(fr.copyRule(rule))
+}
+
+// UpdateRule 将规则写回所在的文件，与MySQL实现一致，版本号不匹配或规则不存在时返回*domain.VersionConflictError
+func (fr *FileRuleRepository) UpdateRule(_ context.Context, rule *domain.Rule) error {
+	if fr.readOnly {
+		return ErrReadOnlyRuleStore
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.refresh(); err != nil {
+		return err
+	}
+
+	old, f := fr.lookup(rule.ID)
+	if old == nil || old.Version != rule.Version-1 {
+		return &domain.VersionConflictError{RuleID: rule.ID, Expected: rule.Version - 1}
+	}
+	updated := fr.copyRule(rule)
+	updated.Namespace, updated.Path, updated.Method = old.Namespace, old.Path, old.Method
+	return fr.replace(f, updated)
+}
+
+// GetRuleByID 获取规则
+func (fr *FileRuleRepository) GetRuleByID(_ context.Context, rid string) (*domain.Rule, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.refresh(); err != nil {
+		return nil, err
+	}
+
+	rule, _ := fr.lookup(rid)
+	if rule == nil {
+		return nil, &domain.RuleNotExistError{RuleID: rid}
+	}
+	return fr.copyRule(rule), nil
+}
+
+// DeleteRule 从所在的文件中删除规则，文件中没有其他规则时删除文件
+func (fr *FileRuleRepository) DeleteRule(_ context.Context, rid string, version int) error {
+	if fr.readOnly {
+		return ErrReadOnlyRuleStore
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.refresh(); err != nil {
+		return err
+	}
+
+	rule, f := fr.lookup(rid)
+	if version != domain.AnyVersion && (rule == nil || rule.Version != version) {
+		return &domain.VersionConflictError{RuleID: rid, Expected: version}
+	}
+	if rule == nil {
+		return nil
+	}
+	return fr.drop(f, rule)
+}
+
+// drop 将规则从所在的文件中删除
+func (fr *FileRuleRepository) drop(f *ruleFile, rule *domain.Rule) error {
+	old := f.rules
+	f.rules = make([]*domain.Rule, 0, len(old)-1)
+	for _, r := range old {
+		if r.ID != rule.ID {
+			f.rules = append(f.rules, r)
+		}
+	}
+	if err := fr.save(f); err != nil {
+		f.rules = old
+		return err
+	}
+	delete(fr.rules, rule.ID)
+	delete(fr.apis, apiKey(rule.Namespace, rule.Path, rule.Method))
+	return nil
+}
+
+// Export 检查文件变化并导出所有有效的规则，按规则ID排序
+func (fr *FileRuleRepository) Export(_ context.Context) ([]*domain.Rule, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.refresh(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(fr.rules))
+	for rid := range fr.rules {
+		ids = append(ids, rid)
+	}
+	sort.Strings(ids)
+	rules := make([]*domain.Rule, len(ids))
+	for i, rid := range ids {
+		rule, _ := fr.lookup(rid)
+		rules[i] = fr.copyRule(rule)
+	}
+	return rules, nil
+}
+
+// Import 导入规则，ID已存在的规则在所在文件中覆盖，其他规则写入新文件
+func (fr *FileRuleRepository) Import(ctx context.Context, rules ...*domain.Rule) error {
+	return fr.Replace(ctx, rules, nil, nil)
+}
+
+// Replace 删除指定的规则并导入规则，写入前检查版本号及唯一约束；文件逐个写入，中途失败时已写入的文件不会回滚
+func (fr *FileRuleRepository) Replace(_ context.Context, rules []*domain.Rule, deleted []string, expected map[string]int) error {
+	if fr.readOnly {
+		return ErrReadOnlyRuleStore
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.refresh(); err != nil {
+		return err
+	}
+
+	for rid, version := range expected {
+		if old, _ := fr.lookup(rid); old == nil || old.Version != version {
+			return &domain.VersionConflictError{RuleID: rid, Expected: version}
+		}
+	}
+
+	// 写入前检查唯一约束，避免只导入了一部分
+	apis := map[string]string{}
+	for key, rid := range fr.apis {
+		apis[key] = rid
+	}
+	for _, rule := range rules {
+		if old, _ := fr.lookup(rule.ID); old != nil {
+			delete(apis, apiKey(old.Namespace, old.Path, old.Method))
+		}
+	}
+	for _, rid := range deleted {
+		if old, _ := fr.lookup(rid); old != nil {
+			delete(apis, apiKey(old.Namespace, old.Path, old.Method))
+		}
+	}
+	for _, rule := range rules {
+		key := apiKey(rule.Namespace, rule.Path, rule.Method)
+		if _, exists := apis[key]; exists {
+			return errors.New("duplicate rule api: " + rule.Method + " " + rule.Path)
+		}
+		apis[key] = rule.ID
+	}
+
+	for _, rid := range deleted {
+		if old, f := fr.lookup(rid); old != nil {
+			if err := fr.drop(f, old); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rule := range rules {
+		imported := fr.copyRule(rule)
+		old, f := fr.lookup(rule.ID)
+		if old == nil {
+			if err := fr.create(imported); err != nil {
+				return err
+			}
+			continue
+		}
+		delete(fr.apis, apiKey(old.Namespace, old.Path, old.Method))
+		if err := fr.replace(f, imported); err != nil {
+			return err
+		}
+		fr.apis[apiKey(imported.Namespace, imported.Path, imported.Method)] = imported.ID
+	}
+	return nil
+}
+
+// writeFileAtomic 先写入临时文件再替换，避免中途失败损坏已有文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,187 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/application"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+)
+
+const (
+	yamlRuleFile = `# 用户接口
+- path: /user
+  method: get
+  responses:
+    - is_default: true
+      response:
+        body: deepmock
+- path: /order
+  method: post
+  responses:
+    - is_default: true
+      response:
+        status_code: 201
+`
+	jsonRuleFile = `{"path": "/pay", "method": "POST", "responses": [{"is_default": true, "response": {"body": "ok"}}]}`
+)
+
+func writeRuleFile(t *testing.T, path, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	// 确保修改时间发生变化
+	future := time.Now().Add(time.Duration(len(content)) * time.Second)
+	assert.NoError(t, os.Chtimes(path, future, future))
+}
+
+func TestFileRuleRepository(t *testing.T) {
+	repo, err := NewFileRuleRepository(t.TempDir(), false, application.RuleConverter)
+	assert.NoError(t, err)
+	testRuleRepository(t, repo)
+}
+
+func TestFileRuleRepository_Load(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, filepath.Join(dir, "user.yaml"), yamlRuleFile)
+	writeRuleFile(t, filepath.Join(dir, "pay", "pay.json"), jsonRuleFile)
+	writeRuleFile(t, filepath.Join(dir, "broken.yml"), "path: [")
+	writeRuleFile(t, filepath.Join(dir, "invalid.json"), `{"path": "/invalid", "method": "GET"}`)
+	writeRuleFile(t, filepath.Join(dir, "zz_duplicate.json"), `{"path": "/user", "method": "GET", "responses": [{"is_default": true, "response": {}}]}`)
+	writeRuleFile(t, filepath.Join(dir, "README.md"), "ignored")
+
+	repo, err := NewFileRuleRepository(dir, true, application.RuleConverter)
+	assert.NoError(t, err)
+	rules, err := repo.Export(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+	assert.Len(t, repo.files, 5)
+
+	rid := misc.GenID([]byte("/user"), []byte("GET"))
+	rule, err := repo.GetRuleByID(context.TODO(), rid)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rule.Version)
+	assert.Equal(t, "deepmock", rule.Regulations[0].Template.Body)
+
+	// 热加载：修改内容后版本号加1，修复后的文件重新生效
+	writeRuleFile(t, filepath.Join(dir, "user.yaml"), yamlRuleFile+"  priority: 1\n")
+	writeRuleFile(t, filepath.Join(dir, "broken.yml"), "path: /broken\nmethod: GET\nresponses:\n  - is_default: true\n    response: {}\n")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "pay", "pay.json")))
+	rules, err = repo.Export(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+	rule, err = repo.GetRuleByID(context.TODO(), rid)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rule.Version)
+	rule, err = repo.GetRuleByID(context.TODO(), misc.GenID([]byte("/order"), []byte("POST")))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rule.Version)
+	assert.Equal(t, 1, rule.Priority)
+
+	assert.True(t, errors.Is(repo.CreateRule(context.TODO(), buildRule("", "/new", 0)), ErrReadOnlyRuleStore))
+	assert.True(t, errors.Is(repo.DeleteRule(context.TODO(), rid, domain.AnyVersion), ErrReadOnlyRuleStore))
+}
+
+func TestFileRuleRepository_Write(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, filepath.Join(dir, "user.yaml"), yamlRuleFile)
+	repo, err := NewFileRuleRepository(dir, false, application.RuleConverter)
+	assert.NoError(t, err)
+	ctx := context.TODO()
+
+	created := buildRule("", "/new", 0)
+	created.SupplyID()
+	assert.NoError(t, repo.CreateRule(ctx, created))
+	assert.Error(t, repo.CreateRule(ctx, created))
+	assert.FileExists(t, filepath.Join(dir, created.ID+".yaml"))
+
+	rid := misc.GenID([]byte("/user"), []byte("GET"))
+	rule, err := repo.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	rule.Version++
+	rule.Priority = 5
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	rule.Priority = 6 // 版本号不匹配时不更新
+	assert.True(t, errors.Is(repo.UpdateRule(ctx, rule), domain.ErrVersionConflict))
+
+	// 重新从文件加载，确认修改已写回
+	reloaded, err := NewFileRuleRepository(dir, true, application.RuleConverter)
+	assert.NoError(t, err)
+	rule, err = reloaded.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, rule.Priority)
+	rules, err := reloaded.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+
+	data, err := os.ReadFile(filepath.Join(dir, "user.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "- id: "+rid)
+
+	imported := buildRule("", "/imported", 0)
+	imported.SupplyID()
+	assert.Error(t, repo.Import(ctx, imported, buildRule(imported.ID, "/imported", 0)))
+	assert.NoError(t, repo.Import(ctx, imported, created))
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 4)
+
+	assert.NoError(t, repo.DeleteRule(ctx, created.ID, domain.AnyVersion))
+	_, err = os.Stat(filepath.Join(dir, created.ID+".yaml"))
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, repo.DeleteRule(ctx, rid, domain.AnyVersion))
+	assert.FileExists(t, filepath.Join(dir, "user.yaml"))
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+}
+
+func TestFileRuleRepository_Version(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user.yaml")
+	writeRuleFile(t, path, yamlRuleFile)
+	repo, err := NewFileRuleRepository(dir, false, application.RuleConverter)
+	assert.NoError(t, err)
+	ctx := context.TODO()
+
+	rid := misc.GenID([]byte("/user"), []byte("GET"))
+	for i := 0; i < 2; i++ {
+		rule, err := repo.GetRuleByID(ctx, rid)
+		assert.NoError(t, err)
+		rule.Version++
+		assert.NoError(t, repo.UpdateRule(ctx, rule))
+	}
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "version: 2")
+
+	// 重启后版本号保持不变
+	reloaded, err := NewFileRuleRepository(dir, false, application.RuleConverter)
+	assert.NoError(t, err)
+	rule, err := reloaded.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rule.Version)
+	assert.True(t, errors.Is(reloaded.DeleteRule(ctx, rid, 1), domain.ErrVersionConflict))
+
+	// 其他实例写入的版本号直接生效
+	rule.Version++
+	assert.NoError(t, reloaded.UpdateRule(ctx, rule))
+	rule, err = repo.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rule.Version)
+
+	// 手工修改文件内容而未修改版本号时，版本号加1
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	writeRuleFile(t, path, strings.Replace(string(data), "body: deepmock", "body: changed", 1))
+	rule, err = repo.GetRuleByID(ctx, rid)
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", rule.Regulations[0].Template.Body)
+	assert.Equal(t, 4, rule.Version)
+}
@@ -0,0 +1,125 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/wosai/deepmock/domain"
+)
+
+type (
+	// MemoryRuleHistoryRepository RuleHistoryRepository的内存存储实现，服务重启后历史版本丢失
+	MemoryRuleHistoryRepository struct {
+		revisions map[string][]*revisionRecord
+		mu        sync.RWMutex
+	}
+
+	// revisionRecord 以JSON编码保存的历史版本记录，Rule为ruleRecord的JSON编码
+	revisionRecord struct {
+		RuleID   string          `json:"rule_id"`
+		Revision int             `json:"revision"`
+		Action   string          `json:"action"`
+		Author   string          `json:"author,omitempty"`
+		CTime    time.Time       `json:"ctime"`
+		Rule     json.RawMessage `json:"rule,omitempty"`
+	}
+)
+
+func newRevisionRecord(rev *domain.RuleRevision) (*revisionRecord, error) {
+	record := &revisionRecord{
+		RuleID:   rev.RuleID,
+		Revision: rev.Revision,
+		Action:   string(rev.Action),
+		Author:   rev.Author,
+		CTime:    rev.CTime,
+	}
+	if rev.Rule != nil {
+		do, err := convertRuleEntity(rev.Rule)
+		if err != nil {
+			return nil, err
+		}
+		do.MTime = rev.Rule.MTime
+		if record.Rule, err = json.Marshal(newRuleRecord(do)); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+func (rr *revisionRecord) entity() (*domain.RuleRevision, error) {
+	rev := &domain.RuleRevision{
+		RuleID:   rr.RuleID,
+		Revision: rr.Revision,
+		Action:   domain.RevisionAction(rr.Action),
+		Author:   rr.Author,
+		CTime:    rr.CTime,
+	}
+	if len(rr.Rule) > 0 {
+		record := new(ruleRecord)
+		if err := json.Unmarshal(rr.Rule, record); err != nil {
+			return nil, err
+		}
+		rule, err := convertRuleDO(record.dataObject())
+		if err != nil {
+			return nil, err
+		}
+		rev.Rule = rule
+	}
+	return rev, nil
+}
+
+func revisionNotFound(rid string, revision int) error {
+	return errors.New("cannot find revision " + strconv.Itoa(revision) + " of rule: " + rid)
+}
+
+// NewMemoryRuleHistoryRepository 工厂函数
+func NewMemoryRuleHistoryRepository() *MemoryRuleHistoryRepository {
+	return &MemoryRuleHistoryRepository{revisions: map[string][]*revisionRecord{}}
+}
+
+// SaveRevision 保存历史版本，并分配版本号及创建时间
+func (mh *MemoryRuleHistoryRepository) SaveRevision(_ context.Context, rev *domain.RuleRevision) error {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	rev.Revision = len(mh.revisions[rev.RuleID]) + 1
+	rev.CTime = time.Now()
+	record, err := newRevisionRecord(rev)
+	if err != nil {
+		return err
+	}
+	mh.revisions[rev.RuleID] = append(mh.revisions[rev.RuleID], record)
+	return nil
+}
+
+// ListRevisions 按版本号升序返回规则的所有历史版本
+func (mh *MemoryRuleHistoryRepository) ListRevisions(_ context.Context, rid string) ([]*domain.RuleRevision, error) {
+	mh.mu.RLock()
+	records := mh.revisions[rid]
+	mh.mu.RUnlock()
+
+	revisions := make([]*domain.RuleRevision, len(records))
+	for index, record := range records {
+		rev, err := record.entity()
+		if err != nil {
+			return nil, err
+		}
+		revisions[index] = rev
+	}
+	return revisions, nil
+}
+
+// GetRevision 获取指定的历史版本
+func (mh *MemoryRuleHistoryRepository) GetRevision(_ context.Context, rid string, revision int) (*domain.RuleRevision, error) {
+	mh.mu.RLock()
+	records := mh.revisions[rid]
+	mh.mu.RUnlock()
+
+	if revision < 1 || revision > len(records) {
+		return nil, revisionNotFound(rid, revision)
+	}
+	return records[revision-1].entity()
+}
@@ -0,0 +1,60 @@
+package infrastructure
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+)
+
+func testRuleHistoryRepository(t *testing.T, repo domain.RuleHistoryRepository) {
+	ctx := context.TODO()
+
+	created := &domain.RuleRevision{RuleID: "a", Action: domain.RevisionActionCreate, Author: "alice", Rule: buildRule("a", "/a", 0)}
+	assert.NoError(t, repo.SaveRevision(ctx, created))
+	assert.Equal(t, 1, created.Revision)
+	assert.False(t, created.CTime.IsZero())
+
+	updated := buildRule("a", "/a", 1)
+	updated.Priority = 9
+	assert.NoError(t, repo.SaveRevision(ctx, &domain.RuleRevision{RuleID: "a", Action: domain.RevisionActionUpdate, Author: "bob", Rule: updated}))
+	deleted := &domain.RuleRevision{RuleID: "a", Action: domain.RevisionActionDelete}
+	assert.NoError(t, repo.SaveRevision(ctx, deleted))
+	assert.Equal(t, 3, deleted.Revision)
+	assert.NoError(t, repo.SaveRevision(ctx, &domain.RuleRevision{RuleID: "b", Action: domain.RevisionActionCreate, Rule: buildRule("b", "/b", 0)}))
+
+	revisions, err := repo.ListRevisions(ctx, "a")
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+	assert.Equal(t, []domain.RevisionAction{domain.RevisionActionCreate, domain.RevisionActionUpdate, domain.RevisionActionDelete},
+		[]domain.RevisionAction{revisions[0].Action, revisions[1].Action, revisions[2].Action})
+	assert.Nil(t, revisions[2].Rule)
+
+	rev, err := repo.GetRevision(ctx, "a", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", rev.Author)
+	assert.Equal(t, 1, rev.Rule.Version)
+	assert.Equal(t, 9, rev.Rule.Priority)
+	assert.Equal(t, "/a", rev.Rule.Regulations[0].Template.Body)
+
+	_, err = repo.GetRevision(ctx, "a", 4)
+	assert.Error(t, err)
+	_, err = repo.GetRevision(ctx, "c", 1)
+	assert.Error(t, err)
+	revisions, err = repo.ListRevisions(ctx, "c")
+	assert.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func TestMemoryRuleHistoryRepository(t *testing.T) {
+	testRuleHistoryRepository(t, NewMemoryRuleHistoryRepository())
+}
+
+func TestBoltRuleRepository_History(t *testing.T) {
+	repo, err := NewBoltRuleRepository(filepath.Join(t.TempDir(), "deepmock.db"))
+	assert.NoError(t, err)
+	defer repo.Close()
+	testRuleHistoryRepository(t, repo)
+}
@@ -3,6 +3,7 @@ package infrastructure
 import (
 	"bytes"
 	"context"
+	"sort"
 	"sync"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -14,9 +15,11 @@ import (
 type (
 	// ExecutorRepository ExecutorRepository的内存存储库实现
 	ExecutorRepository struct {
-		executors map[string]*domain.Executor
-		cache     *lru.ARCCache
-		mu        sync.RWMutex
+		executors  map[string]*domain.Executor
+		sorted     []*domain.Executor // 按匹配顺序排列的执行器
+		namespaces map[string]int     // 各命名空间的执行器数量
+		cache      *lru.ARCCache
+		mu         sync.RWMutex
 	}
 )
 
@@ -32,18 +35,20 @@ func NewExecutorRepository(size int) *ExecutorRepository {
 	}
 
 	return &ExecutorRepository{
-		executors: map[string]*domain.Executor{},
-		cache:     cache,
+		executors:  map[string]*domain.Executor{},
+		namespaces: map[string]int{},
+		cache:      cache,
 	}
 }
 
-func (er *ExecutorRepository) cacheID(path, method []byte) string {
-	return string(bytes.Join([][]byte{path, method}, delimiter))
+func (er *ExecutorRepository) cacheID(namespace string, path, method []byte) string {
+	return string(bytes.Join([][]byte{[]byte(namespace), path, method}, delimiter))
 }
 
-// FindExecutor 查询执行器
-func (er *ExecutorRepository) FindExecutor(_ context.Context, path, method []byte) (*domain.Executor, bool) {
-	cid := er.cacheID(path, method)
+// FindExecutor 在指定命名空间中查询执行器
+func (er *ExecutorRepository) FindExecutor(_ context.Context, namespace string, path, method []byte) (*domain.Executor, bool) {
+	namespace = domain.NormalizeNamespace(namespace)
+	cid := er.cacheID(namespace, path, method)
 	val, cached := er.cache.Get(cid)
 	// 如果存在缓存，需要再次从executors确认是否还在
 	if cached {
@@ -52,18 +57,21 @@ func (er *ExecutorRepository) FindExecutor(_ context.Context, path, method []byt
 		er.mu.RUnlock()
 
 		if exists {
+			executorCacheHits.Inc()
 			return exe, true
 		}
 		er.cache.Remove(cid) // 已经失效
+		executorCacheMisses.Inc()
 		return nil, false
 	}
+	executorCacheMisses.Inc()
 
-	// 不存在时，需要用正则匹配规则
+	// 不存在时，需要按匹配顺序依次用正则匹配规则
 	er.mu.RLock()
-	for eid, executor := range er.executors {
-		if executor.Match(path, method) {
+	for _, executor := range er.sorted {
+		if executor.Namespace == namespace && executor.Match(path, method) {
 			er.mu.RUnlock()
-			er.cache.Add(cid, eid)
+			er.cache.Add(cid, executor.ID)
 			return executor, true
 		}
 	}
@@ -71,6 +79,16 @@ func (er *ExecutorRepository) FindExecutor(_ context.Context, path, method []byt
 	return nil, false
 }
 
+// ListExecutors 按匹配顺序返回所有执行器
+func (er *ExecutorRepository) ListExecutors(_ context.Context) []*domain.Executor {
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+
+	executors := make([]*domain.Executor, len(er.sorted))
+	copy(executors, er.sorted)
+	return executors
+}
+
 // Purge 清空存储库
 func (er *ExecutorRepository) Purge(_ context.Context) {
 	er.mu.Lock()
@@ -79,7 +97,38 @@ func (er *ExecutorRepository) Purge(_ context.Context) {
 	for k := range er.executors {
 		delete(er.executors, k)
 	}
+	er.sorted = nil
 	er.cache.Purge()
+	er.countNamespaces()
+}
+
+// resort 重新计算执行器的匹配顺序，调用方需持有写锁
+func (er *ExecutorRepository) resort() {
+	sorted := make([]*domain.Executor, 0, len(er.executors))
+	for _, executor := range er.executors {
+		sorted = append(sorted, executor)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Precedes(sorted[j])
+	})
+	er.sorted = sorted
+}
+
+// countNamespaces 按命名空间统计已载入的规则数量，调用方需持有写锁
+func (er *ExecutorRepository) countNamespaces() {
+	namespaces := make(map[string]int, len(er.namespaces))
+	for _, executor := range er.executors {
+		namespaces[executor.Namespace]++
+	}
+	for namespace := range er.namespaces {
+		if _, exists := namespaces[namespace]; !exists {
+			loadedRules.DeleteLabelValues(namespace)
+		}
+	}
+	for namespace, count := range namespaces {
+		loadedRules.WithLabelValues(namespace).Set(float64(count))
+	}
+	er.namespaces = namespaces
 }
 
 // ImportAll 导入所有执行器
@@ -92,13 +141,15 @@ func (er *ExecutorRepository) ImportAll(_ context.Context, executors ...*domain.
 		toDelete[k] = struct{}{}
 	}
 
+	var changed bool
 	for _, executor := range executors {
 		current, exists := er.executors[executor.ID]
 		delete(toDelete, executor.ID)
-		if exists && current.Version == executor.Version { // 记录未变更
+		if exists && sameRevision(current, executor) { // 记录未变更
 			continue
 		}
 		er.executors[executor.ID] = executor // 记录不存在或者版本不同了，都变更
+		changed = true
 	}
 
 	// toDelete中如果还存在数据，即表示需要删除
@@ -107,5 +158,46 @@ func (er *ExecutorRepository) ImportAll(_ context.Context, executors ...*domain.
 			misc.Logger.Info("deleted expired rules", zap.String("rule_id", k))
 			delete(er.executors, k)
 		}
+		changed = true
+	}
+
+	// 执行器集合变化后，匹配顺序与缓存的匹配结果都可能失效
+	if changed {
+		er.resort()
+		er.cache.Purge()
+		er.countNamespaces()
 	}
 }
+
+// Apply 增量更新执行器，updated中的执行器覆盖同ID的执行器，deleted中的执行器被删除
+func (er *ExecutorRepository) Apply(_ context.Context, updated []*domain.Executor, deleted []string) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	var changed bool
+	for _, rid := range deleted {
+		if _, exists := er.executors[rid]; exists {
+			misc.Logger.Info("deleted expired rules", zap.String("rule_id", rid))
+			delete(er.executors, rid)
+			changed = true
+		}
+	}
+	for _, executor := range updated {
+		if current, exists := er.executors[executor.ID]; exists && sameRevision(current, executor) {
+			continue
+		}
+		er.executors[executor.ID] = executor
+		changed = true
+	}
+
+	if changed {
+		er.resort()
+		er.cache.Purge()
+		er.countNamespaces()
+	}
+}
+
+// sameRevision 版本号与修改时间都相同时视为同一份规则，重新导入的规则版本号可能不变
+func sameRevision(a, b *domain.Executor) bool {
+	return a.Version == b.Version && a.MTime.Equal(b.MTime)
+}
@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+)
+
+func buildExecutor(t *testing.T, path string, priority int) *domain.Executor {
+	rule := &domain.Rule{
+		Path:     path,
+		Method:   "GET",
+		Priority: priority,
+		Regulations: []*domain.Regulation{
+			{IsDefault: true, Template: &domain.Template{Body: path}},
+		},
+	}
+	exe, err := rule.To()
+	assert.NoError(t, err)
+	return exe
+}
+
+func TestExecutorRepository_FindExecutor(t *testing.T) {
+	repo := NewExecutorRepository(10)
+	repo.ImportAll(context.TODO(),
+		buildExecutor(t, "/(.*)", 0),
+		buildExecutor(t, "/who(.*)", 0),
+		buildExecutor(t, "/whoami", 0),
+	)
+
+	for i := 0; i < 10; i++ {
+		exe, found := repo.FindExecutor(context.TODO(), domain.DefaultNamespace, []byte("/whoami"), []byte("GET"))
+		assert.True(t, found)
+		assert.Equal(t, "/whoami", exe.Path.String())
+	}
+
+	exe, found := repo.FindExecutor(context.TODO(), domain.DefaultNamespace, []byte("/whoareyou"), []byte("GET"))
+	assert.True(t, found)
+	assert.Equal(t, "/who(.*)", exe.Path.String())
+
+	// 显式的优先级高于路径的具体程度，且导入后缓存失效
+	updated := buildExecutor(t, "/(.*)", 1)
+	updated.Version++
+	repo.ImportAll(context.TODO(),
+		updated,
+		buildExecutor(t, "/who(.*)", 0),
+		buildExecutor(t, "/whoami", 0),
+	)
+	exe, found = repo.FindExecutor(context.TODO(), domain.DefaultNamespace, []byte("/whoami"), []byte("GET"))
+	assert.True(t, found)
+	assert.Equal(t, "/(.*)", exe.Path.String())
+
+	_, found = repo.FindExecutor(context.TODO(), domain.DefaultNamespace, []byte("/whoami"), []byte("POST"))
+	assert.False(t, found)
+}
+
+func TestExecutorRepository_FindExecutorInNamespace(t *testing.T) {
+	payment := &domain.Rule{
+		Namespace: "payment",
+		Path:      "/whoami",
+		Method:    "GET",
+		Regulations: []*domain.Regulation{
+			{IsDefault: true, Template: &domain.Template{Body: "payment"}},
+		},
+	}
+	exe, err := payment.To()
+	assert.NoError(t, err)
+
+	repo := NewExecutorRepository(10)
+	repo.ImportAll(context.TODO(), buildExecutor(t, "/whoami", 0), exe)
+
+	for _, namespace := range []string{"", domain.DefaultNamespace, "payment"} {
+		exe, found := repo.FindExecutor(context.TODO(), namespace, []byte("/whoami"), []byte("GET"))
+		assert.True(t, found)
+		assert.Equal(t, domain.NormalizeNamespace(namespace), exe.Namespace)
+	}
+	assert.NotEqual(t, exe.ID, buildExecutor(t, "/whoami", 0).ID)
+
+	_, found := repo.FindExecutor(context.TODO(), "order", []byte("/whoami"), []byte("GET"))
+	assert.False(t, found)
+	assert.Equal(t, map[string]int{domain.DefaultNamespace: 1, "payment": 1}, repo.namespaces)
+}
@@ -2,22 +2,37 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/wosai/deepmock/domain"
 )
 
-// Job AsyncJob的实现
+// Job AsyncJob的实现，存储库支持增量导出时只同步自上次水位线之后变更的规则，并每隔fullPeriod全量同步一次；
+// 定时任务与等待同步的管理接口共享同一次同步，Do同一时间也只有一个在执行
 type Job struct {
-	period   time.Duration
-	rule     domain.RuleRepository
-	executor domain.ExecutorRepository
+	mu         sync.Mutex
+	period     time.Duration
+	fullPeriod time.Duration
+	rule       domain.RuleRepository
+	executor   domain.ExecutorRepository
+	watermark  time.Time
+	lastFull   time.Time
 }
 
-// NewJob 工厂函数
-func NewJob(period time.Duration) *Job {
-	return &Job{period: period}
+// tombstoneRetention 删除记录的保留时间，需大于全量同步的周期
+const tombstoneRetention = 24 * time.Hour
+
+var (
+	// ErrIncrementalSyncUnsupported 存储库不支持增量导出，需要全量导出
+	ErrIncrementalSyncUnsupported = errors.New("incremental sync is unsupported")
+)
+
+// NewJob 工厂函数，fullPeriod不大于0时不做定期全量同步
+func NewJob(period, fullPeriod time.Duration) *Job {
+	return &Job{period: period, fullPeriod: fullPeriod}
 }
 
 // Period 执行周期
@@ -27,31 +42,92 @@ func (job *Job) Period() time.Duration {
 
 // WithRuleRepository 载入规则存储库
 func (job *Job) WithRuleRepository(rr domain.RuleRepository) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
 	job.rule = rr
+	job.watermark = time.Time{}
 }
 
 // WithExecutorRepository 载入执行器存储库
 func (job *Job) WithExecutorRepository(er domain.ExecutorRepository) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
 	job.executor = er
 }
 
 // Do 任务逻辑
 func (job *Job) Do() error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	start := time.Now()
+	err := job.do()
+	syncJobDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		syncJobFailures.Inc()
+	}
+	return err
+}
+
+func (job *Job) do() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if ir, ok := job.rule.(domain.IncrementalRuleRepository); ok {
+		full := job.watermark.IsZero() || (job.fullPeriod > 0 && time.Since(job.lastFull) >= job.fullPeriod)
+		since := job.watermark
+		if full {
+			since = time.Time{}
+		}
+		changes, err := ir.ExportChanges(ctx, since)
+		switch {
+		case err == nil:
+			return job.apply(ctx, changes, full)
+		case !errors.Is(err, ErrIncrementalSyncUnsupported):
+			return err
+		}
+	}
+
 	rules, err := job.rule.Export(ctx)
 	if err != nil {
 		return err
 	}
-	executors := make([]*domain.Executor, len(rules))
-	for index, rule := range rules {
+	return job.apply(ctx, &domain.RuleChanges{Updated: rules}, true)
+}
+
+// apply 只重新编译有变化的规则，full为true时changes.Updated为所有规则；禁用的规则从执行器中移除
+func (job *Job) apply(ctx context.Context, changes *domain.RuleChanges, full bool) error {
+	current := map[string]*domain.Executor{}
+	for _, executor := range job.executor.ListExecutors(ctx) {
+		current[executor.ID] = executor
+	}
+
+	executors := make([]*domain.Executor, 0, len(changes.Updated))
+	var compiled int
+	for _, rule := range changes.Updated {
+		if rule.Disabled {
+			changes.Deleted = append(changes.Deleted, rule.ID)
+			continue
+		}
+		if executor, exists := current[rule.ID]; exists && executor.Version == rule.Version && executor.MTime.Equal(rule.MTime) {
+			executors = append(executors, executor)
+			continue
+		}
 		executor, err := rule.To()
 		if err != nil {
 			return fmt.Errorf("failed to convert Rule to Executor: %s - %w", rule.ID, err)
 		}
-		executors[index] = executor
+		executors = append(executors, executor)
+		compiled++
+	}
+
+	if full {
+		job.executor.ImportAll(ctx, executors...)
+		job.lastFull = time.Now()
+	} else {
+		job.executor.Apply(ctx, executors, changes.Deleted)
+		syncedRules.WithLabelValues("deleted").Add(float64(len(changes.Deleted)))
 	}
-	job.executor.ImportAll(ctx, executors...)
+	job.watermark = changes.Watermark
+	syncedRules.WithLabelValues("compiled").Add(float64(compiled))
 	return nil
 }
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/application"
+	"github.com/wosai/deepmock/domain"
+)
+
+func findExecutor(er *ExecutorRepository, rid string) *domain.Executor {
+	for _, executor := range er.ListExecutors(context.TODO()) {
+		if executor.ID == rid {
+			return executor
+		}
+	}
+	return nil
+}
+
+func buildSyncedRule(path string) *domain.Rule {
+	rule := buildRule("", path, 0)
+	rule.SupplyID()
+	return rule
+}
+
+func TestJob_IncrementalSync(t *testing.T) {
+	ctx := context.TODO()
+	repo := NewMemoryRuleRepository()
+	executors := NewExecutorRepository(10)
+	job := NewJob(time.Second, time.Hour)
+	job.WithRuleRepository(NewTracedRuleRepository(repo, StorageDriverMemory))
+	job.WithExecutorRepository(executors)
+
+	ra, rb, rc := buildSyncedRule("/a"), buildSyncedRule("/b"), buildSyncedRule("/c")
+	assert.NoError(t, repo.CreateRule(ctx, ra))
+	assert.NoError(t, repo.CreateRule(ctx, rb))
+	assert.NoError(t, job.Do())
+	assert.False(t, job.watermark.IsZero())
+	assert.Len(t, executors.ListExecutors(ctx), 2)
+	a := findExecutor(executors, ra.ID)
+
+	// 只同步变更的规则，未变更的执行器不重新编译
+	watermark := job.watermark
+	rule, err := repo.GetRuleByID(ctx, rb.ID)
+	assert.NoError(t, err)
+	rule.Version++
+	rule.Priority = 1
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	assert.NoError(t, repo.DeleteRule(ctx, ra.ID, domain.AnyVersion))
+	assert.NoError(t, repo.CreateRule(ctx, rc))
+
+	changes, err := repo.ExportChanges(ctx, watermark)
+	assert.NoError(t, err)
+	assert.Len(t, changes.Updated, 2)
+	assert.Equal(t, []string{ra.ID}, changes.Deleted)
+	assert.True(t, changes.Watermark.After(watermark))
+
+	assert.NoError(t, job.Do())
+	assert.Nil(t, findExecutor(executors, ra.ID))
+	assert.Equal(t, 1, findExecutor(executors, rb.ID).Priority)
+	assert.NotNil(t, findExecutor(executors, rc.ID))
+
+	// 重新创建被删除的规则
+	assert.NoError(t, repo.CreateRule(ctx, ra))
+	c := findExecutor(executors, rc.ID)
+	assert.NoError(t, job.Do())
+	assert.NotNil(t, findExecutor(executors, ra.ID))
+	assert.True(t, a != findExecutor(executors, ra.ID))
+	assert.Same(t, c, findExecutor(executors, rc.ID))
+
+	// 重新导入的规则版本号不变，也需要同步
+	imported := buildSyncedRule("/c")
+	imported.Regulations[0].Template.Body = "imported"
+	assert.NoError(t, repo.Import(ctx, imported))
+	assert.NoError(t, job.Do())
+	assert.True(t, c != findExecutor(executors, rc.ID))
+	assert.Len(t, executors.ListExecutors(ctx), 3)
+}
+
+func TestJob_FullSyncFallback(t *testing.T) {
+	ctx := context.TODO()
+	repo, err := NewFileRuleRepository(t.TempDir(), false, application.RuleConverter)
+	assert.NoError(t, err)
+	executors := NewExecutorRepository(10)
+	job := NewJob(time.Second, 0)
+	job.WithRuleRepository(NewTracedRuleRepository(repo, StorageDriverFile))
+	job.WithExecutorRepository(executors)
+
+	created := buildSyncedRule("/a")
+	assert.NoError(t, repo.CreateRule(ctx, created))
+	assert.NoError(t, job.Do())
+	assert.True(t, job.watermark.IsZero())
+	a := findExecutor(executors, created.ID)
+	assert.NotNil(t, a)
+
+	assert.NoError(t, job.Do())
+	assert.Same(t, a, findExecutor(executors, created.ID))
+
+	// 禁用的规则保留在文件中，但从执行器中移除
+	rule, err := repo.GetRuleByID(ctx, created.ID)
+	assert.NoError(t, err)
+	rule.SetDisabled(true)
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	assert.NoError(t, job.Do())
+	assert.Nil(t, findExecutor(executors, created.ID))
+	rules, err := repo.Export(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.True(t, rules[0].Disabled)
+	}
+
+	rule.SetDisabled(false)
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	assert.NoError(t, job.Do())
+	assert.NotNil(t, findExecutor(executors, created.ID))
+
+	assert.NoError(t, repo.DeleteRule(ctx, created.ID, domain.AnyVersion))
+	assert.NoError(t, job.Do())
+	assert.Len(t, executors.ListExecutors(ctx), 0)
+}
@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/wosai/deepmock/domain"
+)
+
+type (
+	// JournalRepository JournalRepository的内存存储库实现，超出容量后丢弃最早的请求日志
+	JournalRepository struct {
+		entries []*domain.JournalEntry
+		next    int
+		full    bool
+		mu      sync.RWMutex
+	}
+)
+
+// NewJournalRepository 工厂函数
+func NewJournalRepository(size int) *JournalRepository {
+	if size <= 0 {
+		size = 1
+	}
+	return &JournalRepository{entries: make([]*domain.JournalEntry, size)}
+}
+
+// Append 写入请求日志
+func (jr *JournalRepository) Append(_ context.Context, entry *domain.JournalEntry) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	jr.entries[jr.next] = entry
+	jr.next++
+	if jr.next == len(jr.entries) {
+		jr.next = 0
+		jr.full = true
+	}
+}
+
+// ordered 按写入顺序返回所有请求日志，调用方需持有锁
+func (jr *JournalRepository) ordered() []*domain.JournalEntry {
+	ordered := jr.entries[:jr.next]
+	if jr.full {
+		ordered = append(append([]*domain.JournalEntry{}, jr.entries[jr.next:]...), ordered...)
+	}
+	return ordered
+}
+
+// List 按写入顺序返回满足条件的请求日志
+func (jr *JournalRepository) List(_ context.Context, filter domain.JournalFilter) []*domain.JournalEntry {
+	jr.mu.RLock()
+	defer jr.mu.RUnlock()
+
+	entries := make([]*domain.JournalEntry, 0)
+	for _, entry := range jr.ordered() {
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries
+}
+
+// Clear 删除满足条件的请求日志，其余请求日志保持写入顺序，查询条件的Limit不生效
+func (jr *JournalRepository) Clear(_ context.Context, filter domain.JournalFilter) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	filter.Limit = 0
+	entries := make([]*domain.JournalEntry, len(jr.entries))
+	var next int
+	for _, entry := range jr.ordered() {
+		if !filter.Match(entry) {
+			entries[next] = entry
+			next++
+		}
+	}
+	jr.entries = entries
+	jr.next = next % len(entries)
+	jr.full = next == len(entries)
+}
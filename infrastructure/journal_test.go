@@ -0,0 +1,64 @@
+package infrastructure
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+)
+
+func TestJournalRepository(t *testing.T) {
+	jr := NewJournalRepository(3)
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		entry := &domain.JournalEntry{ID: uint64(i), Method: "GET", Path: "/pay/query", ReceivedAt: now.Add(time.Duration(i) * time.Second)}
+		if i%2 == 0 {
+			entry.Path = "/pay/notify"
+			entry.RuleID = "notify"
+		}
+		jr.Append(context.Background(), entry)
+	}
+
+	entries := jr.List(context.Background(), domain.JournalFilter{})
+	assert.Len(t, entries, 3)
+	assert.Equal(t, []uint64{3, 4, 5}, []uint64{entries[0].ID, entries[1].ID, entries[2].ID})
+
+	entries = jr.List(context.Background(), domain.JournalFilter{RuleID: "notify"})
+	assert.Len(t, entries, 1)
+	assert.EqualValues(t, 4, entries[0].ID)
+
+	entries = jr.List(context.Background(), domain.JournalFilter{Path: regexp.MustCompile(`^/pay/query$`), Since: now.Add(4 * time.Second)})
+	assert.Len(t, entries, 1)
+	assert.EqualValues(t, 5, entries[0].ID)
+
+	entries = jr.List(context.Background(), domain.JournalFilter{Limit: 2})
+	assert.Equal(t, []uint64{4, 5}, []uint64{entries[0].ID, entries[1].ID})
+
+	jr.Clear(context.Background(), domain.JournalFilter{})
+	assert.Empty(t, jr.List(context.Background(), domain.JournalFilter{}))
+}
+
+func TestJournalRepository_Namespace(t *testing.T) {
+	jr := NewJournalRepository(3)
+	for i, namespace := range []string{"payment", domain.DefaultNamespace, "payment"} {
+		jr.Append(context.Background(), &domain.JournalEntry{ID: uint64(i + 1), Namespace: namespace, Method: "GET", Path: "/pay/query"})
+	}
+
+	entries := jr.List(context.Background(), domain.JournalFilter{Namespace: "payment"})
+	assert.Equal(t, []uint64{1, 3}, []uint64{entries[0].ID, entries[1].ID})
+
+	jr.Clear(context.Background(), domain.JournalFilter{Namespace: "payment"})
+	entries = jr.List(context.Background(), domain.JournalFilter{})
+	assert.Len(t, entries, 1)
+	assert.Equal(t, domain.DefaultNamespace, entries[0].Namespace)
+
+	// 清理后继续按写入顺序保留最近的请求日志
+	for i := 4; i <= 6; i++ {
+		jr.Append(context.Background(), &domain.JournalEntry{ID: uint64(i), Namespace: "payment"})
+	}
+	entries = jr.List(context.Background(), domain.JournalFilter{})
+	assert.Equal(t, []uint64{4, 5, 6}, []uint64{entries[0].ID, entries[1].ID, entries[2].ID})
+}
@@ -0,0 +1,375 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
+)
+
+const (
+	// StorageDriverMySQL 规则存储在MySQL中
+	StorageDriverMySQL = "mysql"
+	// StorageDriverMemory 规则存储在内存中，可选择在关闭时保存快照
+	StorageDriverMemory = "memory"
+)
+
+type (
+	// MemoryRuleRepository RuleRepository的内存存储实现，与MySQL实现保持相同的语义
+	MemoryRuleRepository struct {
+		rules      map[string]*types.RuleDO
+		apis       map[string]string    // path与method的唯一索引，value为规则ID
+		tombstones map[string]time.Time // 删除记录，value为删除时间
+		mu         sync.RWMutex
+	}
+
+	// ruleRecord 以JSON编码保存的规则记录，字段含义与db.sql一致，各JSON字段原样保存以便阅读
+	ruleRecord struct {
+		ID        string          `json:"id"`
+		Namespace string          `json:"namespace,omitempty"`
+		Path      string          `json:"path"`
+		Method    string          `json:"method"`
+		Variable  json.RawMessage `json:"variable,omitempty"`
+		Weight    json.RawMessage `json:"weight,omitempty"`
+		Responses json.RawMessage `json:"responses,omitempty"`
+		Priority  int             `json:"priority"`
+		Scenario  json.RawMessage `json:"scenario,omitempty"`
+		Version   int             `json:"version"`
+		CTime     time.Time       `json:"ctime"`
+		MTime     time.Time       `json:"mtime"`
+		Disabled  bool            `json:"disabled,omitempty"`
+	}
+)
+
+func newRuleRecord(do *types.RuleDO) *ruleRecord {
+	return &ruleRecord{
+		ID:        do.ID,
+		Namespace: do.Namespace,
+		Path:      do.Path,
+		Method:    do.Method,
+		Variable:  do.Variable,
+		Weight:    do.Weight,
+		Responses: do.Responses,
+		Priority:  do.Priority,
+		Scenario:  do.Scenario,
+		Version:   do.Version,
+		CTime:     do.CTime,
+		MTime:     do.MTime,
+		Disabled:  do.Disabled,
+	}
+}
+
+func (rr *ruleRecord) dataObject() *types.RuleDO {
+	return &types.RuleDO{
+		ID:        rr.ID,
+		Namespace: rr.Namespace,
+		Path:      rr.Path,
+		Method:    rr.Method,
+		Variable:  rr.Variable,
+		Weight:    rr.Weight,
+		Responses: rr.Responses,
+		Priority:  rr.Priority,
+		Scenario:  rr.Scenario,
+		Version:   rr.Version,
+		CTime:     rr.CTime,
+		MTime:     rr.MTime,
+		Disabled:  rr.Disabled,
+	}
+}
+
+// NewMemoryRuleRepository 工厂函数
+func NewMemoryRuleRepository() *MemoryRuleRepository {
+	return &MemoryRuleRepository{
+		rules:      map[string]*types.RuleDO{},
+		apis:       map[string]string{},
+		tombstones: map[string]time.Time{},
+	}
+}
+
+// apiKey 命名空间、path与method的唯一索引键，默认命名空间的键与引入命名空间之前保持一致
+func apiKey(namespace, path, method string) string {
+	key := path + string(delimiter) + method
+	if namespace = domain.NormalizeNamespace(namespace); namespace != domain.DefaultNamespace {
+		key = namespace + "\x00" + key
+	}
+	return key
+}
+
+// insert 插入记录，违反唯一约束时返回错误，调用方需持有锁
+func (mr *MemoryRuleRepository) insert(do *types.RuleDO) error {
+	if _, exists := mr.rules[do.ID]; exists {
+		return errors.New("duplicate rule id: " + do.ID)
+	}
+	key := apiKey(do.Namespace, do.Path, do.Method)
+	if _, exists := mr.apis[key]; exists {
+		return errors.New("duplicate rule api: " + do.Method + " " + do.Path)
+	}
+	mr.rules[do.ID] = do
+	mr.apis[key] = do.ID
+	return nil
+}
+
+// remove 删除记录，调用方需持有锁
+func (mr *MemoryRuleRepository) remove(rid string) {
+	if do, exists := mr.rules[rid]; exists {
+		delete(mr.apis, apiKey(do.Namespace, do.Path, do.Method))
+		delete(mr.rules, rid)
+	}
+}
+
+// CreateRule 插入新纪录
+func (mr *MemoryRuleRepository) CreateRule(_ context.Context, rule *domain.Rule) error {
+	do, err := convertRuleEntity(rule)
+	if err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	do.CTime = time.Now() // 在锁内设置时间，保证修改时间与增量同步的水位线有序
+	do.MTime = do.CTime
+	return mr.insert(do)
+}
+
+// UpdateRule 更新记录，与MySQL实现一致，版本号不匹配或规则不存在时返回*domain.VersionConflictError
+func (mr *MemoryRuleRepository) UpdateRule(_ context.Context, rule *domain.Rule) error {
+	do, err := convertRuleEntity(rule)
+	if err != nil {
+		return err
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	old, exists := mr.rules[do.ID]
+	if !exists || old.Version != do.Version-1 {
+		return &domain.VersionConflictError{RuleID: do.ID, Expected: do.Version - 1}
+	}
+	updated := *old
+	updated.Variable = do.Variable
+	updated.Weight = do.Weight
+	updated.Responses = do.Responses
+	updated.Priority = do.Priority
+	updated.Scenario = do.Scenario
+	updated.Version = do.Version
+	updated.Disabled = do.Disabled
+	updated.MTime = time.Now()
+	mr.rules[do.ID] = &updated
+	return nil
+}
+
+// GetRuleByID 获取记录
+func (mr *MemoryRuleRepository) GetRuleByID(_ context.Context, rid string) (*domain.Rule, error) {
+	mr.mu.RLock()
+	do, exists := mr.rules[rid]
+	mr.mu.RUnlock()
+
+	if !exists {
+		return nil, &domain.RuleNotExistError{RuleID: rid}
+	}
+	return convertRuleDO(do)
+}
+
+// DeleteRule 删除记录，同时保留删除记录用于增量同步
+func (mr *MemoryRuleRepository) DeleteRule(_ context.Context, rid string, version int) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	do, exists := mr.rules[rid]
+	if version != domain.AnyVersion && (!exists || do.Version != version) {
+		return &domain.VersionConflictError{RuleID: rid, Expected: version}
+	}
+	if exists {
+		mr.remove(rid)
+		mr.bury(time.Now(), rid)
+	}
+	return nil
+}
+
+// bury 保留删除记录，并清理过期的删除记录
+func (mr *MemoryRuleRepository) bury(now time.Time, rids ...string) {
+	for _, rid := range rids {
+		mr.tombstones[rid] = now
+	}
+	for id, dtime := range mr.tombstones {
+		if now.Sub(dtime) > tombstoneRetention {
+			delete(mr.tombstones, id)
+		}
+	}
+}
+
+// Export 导出记录，包括禁用的规则，按规则ID排序
+func (mr *MemoryRuleRepository) Export(_ context.Context) ([]*domain.Rule, error) {
+	mr.mu.RLock()
+	dataObjects := make([]*types.RuleDO, 0, len(mr.rules))
+	for _, do := range mr.rules {
+		dataObjects = append(dataObjects, do)
+	}
+	mr.mu.RUnlock()
+	sort.Slice(dataObjects, func(i, j int) bool { return dataObjects[i].ID < dataObjects[j].ID })
+
+	entities := make([]*domain.Rule, len(dataObjects))
+	for index, do := range dataObjects {
+		entity, err := convertRuleDO(do)
+		if err != nil {
+			return nil, err
+		}
+		entities[index] = entity
+	}
+	return entities, nil
+}
+
+// ExportChanges 导出修改时间不早于水位线的规则及删除记录，禁用的规则视为删除
+func (mr *MemoryRuleRepository) ExportChanges(_ context.Context, since time.Time) (*domain.RuleChanges, error) {
+	mr.mu.RLock()
+	changes := &domain.RuleChanges{Watermark: since}
+	var dataObjects []*types.RuleDO
+	for _, do := range mr.rules {
+		if do.MTime.Before(since) {
+			continue
+		}
+		if do.MTime.After(changes.Watermark) {
+			changes.Watermark = do.MTime
+		}
+		if do.Disabled {
+			changes.Deleted = append(changes.Deleted, do.ID)
+			continue
+		}
+		dataObjects = append(dataObjects, do)
+	}
+	if !since.IsZero() {
+		for rid, dtime := range mr.tombstones {
+			if dtime.Before(since) {
+				continue
+			}
+			if dtime.After(changes.Watermark) {
+				changes.Watermark = dtime
+			}
+			changes.Deleted = append(changes.Deleted, rid)
+		}
+	}
+	mr.mu.RUnlock()
+
+	changes.Updated = make([]*domain.Rule, len(dataObjects))
+	for index, do := range dataObjects {
+		entity, err := convertRuleDO(do)
+		if err != nil {
+			return nil, err
+		}
+		changes.Updated[index] = entity
+	}
+	return changes, nil
+}
+
+// Import 导入记录，覆盖ID相同的规则；任意规则违反唯一约束时不做任何修改
+func (mr *MemoryRuleRepository) Import(ctx context.Context, rules ...*domain.Rule) error {
+	return mr.Replace(ctx, rules, nil, nil)
+}
+
+// Replace 导入记录并删除指定的规则；任意规则版本号不一致或违反唯一约束时不做任何修改
+func (mr *MemoryRuleRepository) Replace(_ context.Context, rules []*domain.Rule, deleted []string, expected map[string]int) error {
+	dataObjects := make([]*types.RuleDO, len(rules))
+	for index, rule := range rules {
+		do, err := convertRuleEntity(rule)
+		if err != nil {
+			return err
+		}
+		dataObjects[index] = do
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	for rid, version := range expected {
+		if do, exists := mr.rules[rid]; !exists || do.Version != version {
+			return &domain.VersionConflictError{RuleID: rid, Expected: version}
+		}
+	}
+	now := time.Now()
+	for _, do := range dataObjects {
+		do.CTime, do.MTime = now, now
+	}
+
+	// 在副本上执行，失败时原数据不受影响
+	staged := &MemoryRuleRepository{
+		rules: make(map[string]*types.RuleDO, len(mr.rules)+len(dataObjects)),
+		apis:  make(map[string]string, len(mr.apis)+len(dataObjects)),
+	}
+	for rid, do := range mr.rules {
+		staged.rules[rid] = do
+	}
+	for key, rid := range mr.apis {
+		staged.apis[key] = rid
+	}
+	var buried []string
+	for _, rid := range deleted {
+		if _, exists := staged.rules[rid]; exists {
+			staged.remove(rid)
+			buried = append(buried, rid)
+		}
+	}
+	for _, do := range dataObjects {
+		staged.remove(do.ID)
+	}
+	for _, do := range dataObjects {
+		if err := staged.insert(do); err != nil {
+			return err
+		}
+	}
+	mr.rules, mr.apis = staged.rules, staged.apis
+	if len(buried) > 0 {
+		mr.bury(now, buried...)
+	}
+	return nil
+}
+
+// SaveSnapshot 将所有规则保存至文件
+func (mr *MemoryRuleRepository) SaveSnapshot(path string) error {
+	mr.mu.RLock()
+	snapshots := make([]*ruleRecord, 0, len(mr.rules))
+	for _, do := range mr.rules {
+		snapshots = append(snapshots, newRuleRecord(do))
+	}
+	mr.mu.RUnlock()
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID < snapshots[j].ID })
+
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// LoadSnapshot 从文件载入规则并替换当前所有规则，文件不存在时不做任何修改
+func (mr *MemoryRuleRepository) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshots []*ruleRecord
+	if err = json.Unmarshal(data, &snapshots); err != nil {
+		return err
+	}
+
+	loaded := NewMemoryRuleRepository()
+	for _, s := range snapshots {
+		do := s.dataObject()
+		if _, err = convertRuleDO(do); err != nil {
+			return errors.New("bad rule " + s.ID + " in snapshot: " + err.Error())
+		}
+		if err = loaded.insert(do); err != nil {
+			return err
+		}
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.rules, mr.apis, mr.tombstones = loaded.rules, loaded.apis, loaded.tombstones
+	return nil
+}
@@ -0,0 +1,191 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+)
+
+func buildRule(id, path string, version int) *domain.Rule {
+	return &domain.Rule{
+		ID:      id,
+		Path:    path,
+		Method:  "GET",
+		Version: version,
+		Regulations: []*domain.Regulation{
+			{IsDefault: true, Template: &domain.Template{Body: path}},
+		},
+	}
+}
+
+// testRuleRepository 各规则存储实现都需要满足的约定
+func testRuleRepository(t *testing.T, repo domain.RuleRepository) {
+	ctx := context.TODO()
+
+	assert.NoError(t, repo.CreateRule(ctx, buildRule("a", "/a", 1)))
+	assert.Error(t, repo.CreateRule(ctx, buildRule("a", "/other", 1)))
+	assert.Error(t, repo.CreateRule(ctx, buildRule("b", "/a", 1)))
+
+	// 版本号不连续时不更新，返回版本冲突
+	stale := buildRule("a", "/a", 3)
+	stale.Priority = 9
+	var conflict *domain.VersionConflictError
+	if assert.True(t, errors.As(repo.UpdateRule(ctx, stale), &conflict)) {
+		assert.Equal(t, 2, conflict.Expected)
+	}
+	rule, err := repo.GetRuleByID(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rule.Version)
+	assert.Equal(t, 0, rule.Priority)
+
+	// 更新时不能修改path与method
+	rule.Version++
+	rule.Priority = 9
+	rule.Path = "/ignored"
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	rule, err = repo.GetRuleByID(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rule.Version)
+	assert.Equal(t, 9, rule.Priority)
+	assert.Equal(t, "/a", rule.Path)
+
+	// 导入违反唯一约束时不做任何修改
+	assert.Error(t, repo.Import(ctx, buildRule("a", "/a", 1), buildRule("c", "/a", 1)))
+	rule, err = repo.GetRuleByID(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rule.Version)
+	rules, err := repo.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+
+	// 覆盖ID相同的规则，且可以与被覆盖规则使用相同的path与method；导出时按ID排序
+	assert.NoError(t, repo.Import(ctx, buildRule("c", "/c", 1), buildRule("a", "/a", 1)))
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "a", rules[0].ID)
+		assert.Equal(t, 1, rules[0].Version)
+		assert.Equal(t, 0, rules[0].Priority)
+		assert.Equal(t, "c", rules[1].ID)
+	}
+
+	// 禁用的规则仍可查询、导出，并占用path与method
+	rule, err = repo.GetRuleByID(ctx, "c")
+	assert.NoError(t, err)
+	rule.SetDisabled(true)
+	assert.NoError(t, repo.UpdateRule(ctx, rule))
+	rule, err = repo.GetRuleByID(ctx, "c")
+	assert.NoError(t, err)
+	assert.True(t, rule.Disabled)
+	assert.Equal(t, 2, rule.Version)
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	assert.Error(t, repo.CreateRule(ctx, buildRule("d", "/c", 1)))
+
+	// 指定版本号时，版本号不一致或规则不存在都不删除
+	if assert.True(t, errors.As(repo.DeleteRule(ctx, "a", 2), &conflict)) {
+		assert.Equal(t, 2, conflict.Expected)
+	}
+	_, err = repo.GetRuleByID(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.DeleteRule(ctx, "a", 1))
+	assert.True(t, errors.Is(repo.DeleteRule(ctx, "a", 1), domain.ErrVersionConflict))
+
+	// 删除后释放path与method，不校验版本号时重复删除不报错
+	assert.NoError(t, repo.DeleteRule(ctx, "a", domain.AnyVersion))
+	_, err = repo.GetRuleByID(ctx, "a")
+	assert.True(t, errors.Is(err, domain.ErrRuleNotExist))
+	assert.NoError(t, repo.CreateRule(ctx, buildRule("d", "/a", 1)))
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	// 删除与导入整体生效，删除的规则释放path与method
+	assert.Error(t, repo.Replace(ctx, []*domain.Rule{buildRule("e", "/c", 1)}, []string{"d"}, nil))
+	_, err = repo.GetRuleByID(ctx, "d")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Replace(ctx, []*domain.Rule{buildRule("e", "/c", 1)}, []string{"c", "missing"}, nil))
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "d", rules[0].ID)
+		assert.Equal(t, "e", rules[1].ID)
+		assert.False(t, rules[1].Disabled)
+	}
+
+	// 任意规则的版本号不一致时整体不生效
+	err = repo.Replace(ctx, []*domain.Rule{buildRule("e", "/e", 2)}, []string{"d"}, map[string]int{"e": 1, "d": 2})
+	assert.True(t, errors.Is(err, domain.ErrVersionConflict))
+	_, err = repo.GetRuleByID(ctx, "d")
+	assert.NoError(t, err)
+	rule, err = repo.GetRuleByID(ctx, "e")
+	assert.NoError(t, err)
+	assert.Equal(t, "/c", rule.Path)
+	assert.True(t, errors.Is(repo.Replace(ctx, nil, []string{"missing"}, map[string]int{"missing": 1}), domain.ErrVersionConflict))
+	assert.NoError(t, repo.Replace(ctx, []*domain.Rule{buildRule("e", "/e", 2)}, nil, map[string]int{"e": 1}))
+	rule, err = repo.GetRuleByID(ctx, "e")
+	assert.NoError(t, err)
+	assert.Equal(t, "/e", rule.Path)
+	assert.Equal(t, 2, rule.Version)
+
+	assert.NoError(t, repo.Replace(ctx, nil, []string{"e"}, nil))
+	rules, err = repo.Export(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+}
+
+func TestMemoryRuleRepository(t *testing.T) {
+	testRuleRepository(t, NewMemoryRuleRepository())
+}
+
+func TestMemoryRuleRepository_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	repo := NewMemoryRuleRepository()
+	assert.NoError(t, repo.LoadSnapshot(path)) // 文件不存在时忽略
+
+	rule := buildRule("a", "/a", 1)
+	rule.Variable = map[string]interface{}{"name": "deepmock"}
+	assert.NoError(t, repo.Import(context.TODO(), rule, buildRule("b", "/b", 1)))
+	assert.NoError(t, repo.SaveSnapshot(path))
+
+	loaded := NewMemoryRuleRepository()
+	assert.NoError(t, loaded.LoadSnapshot(path))
+	rules, err := loaded.Export(context.TODO())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, rule.Variable, rules[0].Variable)
+		assert.Equal(t, "/b", rules[1].Regulations[0].Template.Body)
+	}
+}
+
+// testRuleRepositoryNamespace 同一path与method在不同命名空间中可以共存，且在同一命名空间中唯一
+func testRuleRepositoryNamespace(t *testing.T, repo domain.RuleRepository) {
+	ctx := context.TODO()
+	payment := buildRule("p", "/a", 0)
+	payment.Namespace = "payment"
+
+	assert.NoError(t, repo.CreateRule(ctx, buildRule("a", "/a", 0)))
+	assert.NoError(t, repo.CreateRule(ctx, payment))
+	duplicated := buildRule("q", "/a", 0)
+	duplicated.Namespace = "payment"
+	assert.Error(t, repo.CreateRule(ctx, duplicated))
+
+	rule, err := repo.GetRuleByID(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.DefaultNamespace, rule.Namespace)
+	rule, err = repo.GetRuleByID(ctx, "p")
+	assert.NoError(t, err)
+	assert.Equal(t, "payment", rule.Namespace)
+
+	assert.NoError(t, repo.DeleteRule(ctx, "p", domain.AnyVersion))
+	assert.NoError(t, repo.CreateRule(ctx, duplicated))
+}
+
+func TestMemoryRuleRepository_Namespace(t *testing.T) {
+	testRuleRepositoryNamespace(t, NewMemoryRuleRepository())
+}
@@ -0,0 +1,39 @@
+package infrastructure
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wosai/deepmock/misc"
+)
+
+var (
+	syncJobDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "deepmock_sync_job_duration_seconds",
+		Help:    "Time spent syncing rules into the executor repository.",
+		Buckets: misc.DefaultLatencyBuckets,
+	})
+	syncJobFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "deepmock_sync_job_failures_total",
+		Help: "Number of failed rule sync jobs.",
+	})
+	syncedRules = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "deepmock_synced_rules_total",
+		Help: "Number of rules compiled or deleted by rule sync jobs.",
+	}, []string{"change"})
+	executorCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "deepmock_executor_cache_hits_total",
+		Help: "Number of executor lookups served from cache.",
+	})
+	executorCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "deepmock_executor_cache_misses_total",
+		Help: "Number of executor lookups not served from cache.",
+	})
+	loadedRules = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "deepmock_rules",
+		Help: "Number of rules loaded into the executor repository.",
+	}, []string{"namespace"})
+	invalidRuleFiles = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "deepmock_invalid_rule_files",
+		Help: "Number of rule files skipped by the file rule store.",
+	})
+)
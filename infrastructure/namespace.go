@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/option"
+)
+
+type (
+	// NamespaceResolver 按Host或路径前缀选择mock请求的命名空间
+	NamespaceResolver struct {
+		hosts    map[string]string  // 不含端口的Host到命名空间
+		prefixes []*namespacePrefix // 按路径前缀长度倒序排列
+	}
+
+	namespacePrefix struct {
+		prefix    string
+		namespace string
+	}
+)
+
+func parseNamespaceMapping(mapping string) (string, string, error) {
+	kv := strings.SplitN(mapping, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return "", "", errors.New("bad namespace mapping: " + mapping)
+	}
+	if err := domain.ValidateNamespace(kv[1]); err != nil {
+		return "", "", err
+	}
+	return kv[0], kv[1], nil
+}
+
+// NewNamespaceResolver 工厂函数，Hosts中每一项的格式为 Host=命名空间，Prefixes中每一项的格式为 路径前缀=命名空间
+func NewNamespaceResolver(opt option.NamespaceOption) (*NamespaceResolver, error) {
+	nr := &NamespaceResolver{hosts: map[string]string{}}
+	for _, mapping := range opt.Hosts {
+		if mapping == "" {
+			continue
+		}
+		host, namespace, err := parseNamespaceMapping(mapping)
+		if err != nil {
+			return nil, err
+		}
+		nr.hosts[strings.ToLower(host)] = namespace
+	}
+
+	for _, mapping := range opt.Prefixes {
+		if mapping == "" {
+			continue
+		}
+		prefix, namespace, err := parseNamespaceMapping(mapping)
+		if err != nil {
+			return nil, err
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, errors.New("bad namespace prefix: " + mapping)
+		}
+		nr.prefixes = append(nr.prefixes, &namespacePrefix{prefix: prefix, namespace: namespace})
+	}
+	sort.SliceStable(nr.prefixes, func(i, j int) bool {
+		return len(nr.prefixes[i].prefix) > len(nr.prefixes[j].prefix)
+	})
+	return nr, nil
+}
+
+// Enabled 是否配置了Host或路径前缀
+func (nr *NamespaceResolver) Enabled() bool {
+	return nr != nil && (len(nr.hosts) > 0 || len(nr.prefixes) > 0)
+}
+
+// Resolve 优先按路径前缀选择命名空间，并去掉请求路径中的前缀；其次按Host选择；都未匹配时返回空字符串
+func (nr *NamespaceResolver) Resolve(ctx *fasthttp.RequestCtx) string {
+	path := ctx.Request.URI().Path()
+	for _, p := range nr.prefixes {
+		rest, ok := trimPathPrefix(path, p.prefix)
+		if ok {
+			ctx.Request.URI().SetPathBytes(rest)
+			return p.namespace
+		}
+	}
+
+	host := string(ctx.Request.Host())
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return nr.hosts[strings.ToLower(host)]
+}
+
+// trimPathPrefix 按路径分段匹配前缀，返回去掉前缀后的路径，如 /payment 匹配 /payment/query 而不匹配 /payments
+func trimPathPrefix(path []byte, prefix string) ([]byte, bool) {
+	if !bytes.HasPrefix(path, []byte(prefix)) {
+		return nil, false
+	}
+	rest := path[len(prefix):]
+	switch {
+	case len(rest) == 0:
+		return []byte("/"), true
+	case rest[0] == '/':
+		return append([]byte(nil), rest...), true
+	default:
+		return nil, false
+	}
+}
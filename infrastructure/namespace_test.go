@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/option"
+)
+
+func TestNewNamespaceResolver(t *testing.T) {
+	nr, err := NewNamespaceResolver(option.NamespaceOption{})
+	assert.NoError(t, err)
+	assert.False(t, nr.Enabled())
+
+	_, err = NewNamespaceResolver(option.NamespaceOption{Prefixes: []string{"payment=payment"}})
+	assert.Error(t, err)
+	_, err = NewNamespaceResolver(option.NamespaceOption{Hosts: []string{"pay.mock=pay ment"}})
+	assert.Error(t, err)
+	_, err = NewNamespaceResolver(option.NamespaceOption{Hosts: []string{"pay.mock"}})
+	assert.Error(t, err)
+}
+
+func TestNamespaceResolver_Resolve(t *testing.T) {
+	nr, err := NewNamespaceResolver(option.NamespaceOption{
+		Hosts:    []string{"Pay.Mock.Local=payment", ""},
+		Prefixes: []string{"/ns/order/=order", "/ns/order/refund=refund"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, nr.Enabled())
+
+	for uri, expected := range map[string][2]string{
+		"http://pay.mock.local:16600/pay/query":     {"payment", "/pay/query"},
+		"http://deepmock/ns/order/query?sn=1":       {"order", "/query"},
+		"http://deepmock/ns/order":                  {"order", "/"},
+		"http://pay.mock.local/ns/order/refund/add": {"refund", "/add"},
+		"http://deepmock/ns/orders/query":           {"", "/ns/orders/query"},
+	} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		assert.Equal(t, expected[0], nr.Resolve(ctx), uri)
+		assert.Equal(t, expected[1], string(ctx.Request.URI().Path()), uri)
+	}
+}
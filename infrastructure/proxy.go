@@ -0,0 +1,149 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/option"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type (
+	// ReverseProxy 将未命中规则的请求转发至上游服务
+	ReverseProxy struct {
+		routes  []*upstream // 按路径前缀长度倒序排列，全局上游地址的前缀为空
+		client  *fasthttp.Client
+		timeout time.Duration
+	}
+
+	upstream struct {
+		prefix string
+		scheme string
+		host   string
+		base   string
+	}
+)
+
+var (
+	hopHeaders = []string{
+		"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+		"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	}
+)
+
+func parseUpstream(prefix, target string) (*upstream, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("unsupported upstream scheme: " + target)
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing upstream host: " + target)
+	}
+	return &upstream{prefix: prefix, scheme: u.Scheme, host: u.Host, base: strings.TrimSuffix(u.Path, "/")}, nil
+}
+
+// NewReverseProxy 工厂函数，Routes中每一项的格式为 路径前缀=上游地址
+func NewReverseProxy(opt option.ProxyOption) (*ReverseProxy, error) {
+	rp := &ReverseProxy{
+		client:  &fasthttp.Client{Name: "DeepMock Proxy", DisablePathNormalizing: true},
+		timeout: opt.Timeout,
+	}
+
+	for _, route := range opt.Routes {
+		if route == "" {
+			continue
+		}
+		kv := strings.SplitN(route, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "/") {
+			return nil, errors.New("bad proxy route: " + route)
+		}
+		up, err := parseUpstream(strings.TrimSuffix(kv[0], "/"), kv[1])
+		if err != nil {
+			return nil, err
+		}
+		rp.routes = append(rp.routes, up)
+	}
+	if opt.Upstream != "" {
+		up, err := parseUpstream("", opt.Upstream)
+		if err != nil {
+			return nil, err
+		}
+		rp.routes = append(rp.routes, up)
+	}
+
+	sort.SliceStable(rp.routes, func(i, j int) bool {
+		return len(rp.routes[i].prefix) > len(rp.routes[j].prefix)
+	})
+	return rp, nil
+}
+
+// Enabled 是否配置了上游地址
+func (rp *ReverseProxy) Enabled() bool {
+	return rp != nil && len(rp.routes) > 0
+}
+
+func (rp *ReverseProxy) route(path []byte) *upstream {
+	for _, up := range rp.routes {
+		if domain.HasPathPrefix(path, up.prefix) {
+			return up
+		}
+	}
+	return nil
+}
+
+// Forward 转发请求并将上游响应原样写回，未配置对应上游时返回false；c中的链路信息只写入发往上游的请求。
+// 上游不可用时写回502响应，超时时写回504响应，并返回转发失败的原因
+func (rp *ReverseProxy) Forward(c context.Context, ctx *fasthttp.RequestCtx) (forwarded bool, err error) {
+	up := rp.route(ctx.Request.URI().Path())
+	if up == nil {
+		return false, nil
+	}
+	c, span := misc.Tracer().Start(c, "proxy "+string(ctx.Method()), trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(attribute.String("net.peer.name", up.host))
+	defer func() { misc.FinishSpan(span, err) }()
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	ctx.Request.CopyTo(req)
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	req.URI().SetScheme(up.scheme)
+	req.URI().SetHost(up.host)
+	req.Header.SetHost(up.host)
+	if up.base != "" {
+		req.URI().SetPath(up.base + string(ctx.Request.URI().Path()))
+	}
+	misc.InjectTraceContext(c, &req.Header) // 转发至上游时延续链路
+
+	misc.Logger.Info("forward request to upstream", zap.ByteString("url", req.URI().FullURI()))
+	if err = rp.client.DoTimeout(req, resp, rp.timeout); err != nil {
+		misc.Logger.Error("failed to forward request", zap.ByteString("url", req.URI().FullURI()), zap.Error(err))
+		status := fasthttp.StatusBadGateway
+		if errors.Is(err, fasthttp.ErrTimeout) {
+			status = fasthttp.StatusGatewayTimeout
+		}
+		ctx.Error(err.Error(), status)
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		return true, err
+	}
+
+	resp.CopyTo(&ctx.Response)
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode()))
+	return true, nil
+}
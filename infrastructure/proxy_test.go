@@ -0,0 +1,104 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/option"
+)
+
+func TestNewReverseProxy(t *testing.T) {
+	rp, err := NewReverseProxy(option.ProxyOption{})
+	assert.NoError(t, err)
+	assert.False(t, rp.Enabled())
+
+	_, err = NewReverseProxy(option.ProxyOption{Routes: []string{"pay=http://pay"}})
+	assert.Error(t, err)
+	_, err = NewReverseProxy(option.ProxyOption{Upstream: "ftp://staging"})
+	assert.Error(t, err)
+
+	rp, err = NewReverseProxy(option.ProxyOption{
+		Upstream: "http://staging",
+		Routes:   []string{"/pay=http://pay", "/pay/refund=https://refund/v2"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, rp.Enabled())
+	assert.Equal(t, "refund", rp.route([]byte("/pay/refund/apply")).host)
+	assert.Equal(t, "pay", rp.route([]byte("/pay/query")).host)
+	assert.Equal(t, "pay", rp.route([]byte("/pay")).host)
+	assert.Equal(t, "staging", rp.route([]byte("/payment")).host)
+	assert.Equal(t, "pay", rp.route([]byte("/pay/refunds")).host)
+	assert.Equal(t, "staging", rp.route([]byte("/user")).host)
+}
+
+func TestReverseProxy_Forward(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("X-Upstream", string(ctx.Host()))
+		ctx.Response.Header.Set("X-Traceparent", string(ctx.Request.Header.Peek(misc.HeaderTraceParent)))
+		ctx.SetStatusCode(fasthttp.StatusAccepted)
+		ctx.SetBodyString(string(ctx.Path()) + "?" + string(ctx.QueryArgs().QueryString()))
+	})
+
+	rp, err := NewReverseProxy(option.ProxyOption{Routes: []string{"/pay=http://pay/base"}, Timeout: time.Second})
+	assert.NoError(t, err)
+	rp.client.Dial = func(string) (net.Conn, error) { return ln.Dial() }
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("http://deepmock/user")
+	forwarded, err := rp.Forward(context.TODO(), ctx)
+	assert.False(t, forwarded)
+	assert.NoError(t, err)
+
+	// 链路信息只写入发往上游的请求，不修改原始请求
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	incoming := new(fasthttp.RequestHeader)
+	incoming.Set(misc.HeaderTraceParent, traceparent)
+	c := misc.ExtractTraceContext(context.TODO(), incoming)
+
+	ctx.Request.SetRequestURI("http://deepmock/pay/query?sn=1")
+	forwarded, err = rp.Forward(c, ctx)
+	assert.True(t, forwarded)
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusAccepted, ctx.Response.StatusCode())
+	assert.Equal(t, "pay", string(ctx.Response.Header.Peek("X-Upstream")))
+	assert.Equal(t, "/base/pay/query?sn=1", string(ctx.Response.Body()))
+	assert.Equal(t, traceparent, string(ctx.Response.Header.Peek("X-Traceparent")))
+	assert.Empty(t, ctx.Request.Header.Peek(misc.HeaderTraceParent))
+}
+
+func TestReverseProxy_ForwardFailure(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	rp, err := NewReverseProxy(option.ProxyOption{Upstream: "http://pay", Timeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	rp.client.Dial = func(string) (net.Conn, error) { return ln.Dial() }
+
+	// 上游超时返回504
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("http://deepmock/pay/query")
+	forwarded, err := rp.Forward(context.TODO(), ctx)
+	assert.True(t, forwarded)
+	assert.True(t, errors.Is(err, fasthttp.ErrTimeout))
+	assert.Equal(t, fasthttp.StatusGatewayTimeout, ctx.Response.StatusCode())
+
+	// 上游不可用返回502
+	assert.NoError(t, ln.Close())
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("http://deepmock/pay/query")
+	forwarded, err = rp.Forward(context.TODO(), ctx)
+	assert.True(t, forwarded)
+	assert.Error(t, err)
+	assert.Equal(t, fasthttp.StatusBadGateway, ctx.Response.StatusCode())
+}
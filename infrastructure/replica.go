@@ -0,0 +1,114 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/types"
+)
+
+// syncWaitPath 各实例等待同步的管理接口
+const syncWaitPath = "/api/v1/sync/wait"
+
+// Replicas 多实例部署时的其他实例，通过管理接口等待各实例完成规则同步
+type Replicas struct {
+	peers  []string
+	client *fasthttp.Client
+}
+
+// NewReplicas 工厂函数，peers为各实例的地址，如 http://deepmock-0:16600，可以包含本实例
+func NewReplicas(peers []string) (*Replicas, error) {
+	replicas := &Replicas{client: &fasthttp.Client{Name: "DeepMock Replicas"}}
+	for _, peer := range peers {
+		if peer == "" {
+			continue
+		}
+		u, err := url.Parse(peer)
+		if err != nil {
+			return nil, err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("bad peer address: " + peer)
+		}
+		replicas.peers = append(replicas.peers, strings.TrimSuffix(peer, "/"))
+	}
+	return replicas, nil
+}
+
+// Enabled 是否配置了其他实例
+func (r *Replicas) Enabled() bool {
+	return r != nil && len(r.peers) > 0
+}
+
+// WaitForSync 并发请求所有实例，直到全部实例完成同步或超时
+func (r *Replicas) WaitForSync(ctx context.Context, wait *types.SyncWaitDTO) error {
+	timeout, err := time.ParseDuration(wait.Timeout)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(wait)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]string, len(r.peers))
+	var wg sync.WaitGroup
+	for index, peer := range r.peers {
+		wg.Add(1)
+		go func(index int, peer string) {
+			defer wg.Done()
+			if err := r.wait(ctx, peer, body, timeout); err != nil {
+				errs[index] = peer + ": " + err.Error()
+			}
+		}(index, peer)
+	}
+	wg.Wait()
+
+	failed := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if e != "" {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (r *Replicas) wait(ctx context.Context, peer string, body []byte, timeout time.Duration) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(peer + syncWaitPath)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	misc.InjectTraceContext(ctx, &req.Header)
+	req.SetBody(body)
+
+	// 对端最多等待timeout，额外留出网络往返的时间
+	if err := r.client.DoTimeout(req, resp, timeout+time.Second); err != nil {
+		return err
+	}
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return errors.New("unexpected status code: " + http.StatusText(resp.StatusCode()))
+	}
+	res := new(types.CommonResponseDTO)
+	if err := json.Unmarshal(resp.Body(), res); err != nil {
+		return err
+	}
+	if res.Code != http.StatusOK {
+		return errors.New(res.ErrorMessage)
+	}
+	return nil
+}
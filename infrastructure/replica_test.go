@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/wosai/deepmock/types"
+)
+
+func TestNewReplicas(t *testing.T) {
+	replicas, err := NewReplicas(nil)
+	assert.NoError(t, err)
+	assert.False(t, replicas.Enabled())
+
+	_, err = NewReplicas([]string{"deepmock-0:16600"})
+	assert.Error(t, err)
+
+	replicas, err = NewReplicas([]string{"http://deepmock-0:16600/", "", "https://deepmock-1"})
+	assert.NoError(t, err)
+	assert.True(t, replicas.Enabled())
+	assert.Equal(t, []string{"http://deepmock-0:16600", "https://deepmock-1"}, replicas.peers)
+}
+
+func TestReplicas_WaitForSync(t *testing.T) {
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		wait := new(types.SyncWaitDTO)
+		_ = json.Unmarshal(ctx.Request.Body(), wait)
+		res := &types.CommonResponseDTO{Code: fasthttp.StatusOK}
+		if string(ctx.Path()) != syncWaitPath || string(ctx.Host()) == "lagging" {
+			res = &types.CommonResponseDTO{Code: fasthttp.StatusBadRequest, ErrorMessage: "timed out"}
+		}
+		if len(wait.Rules) != 1 || wait.Rules[0].Version != 2 {
+			res = &types.CommonResponseDTO{Code: fasthttp.StatusBadRequest, ErrorMessage: "bad request"}
+		}
+		data, _ := json.Marshal(res)
+		ctx.SetBody(data)
+	})
+
+	wait := &types.SyncWaitDTO{Rules: []*types.SyncTargetDTO{{ID: "rule", Version: 2}}, Timeout: "1s"}
+	replicas, err := NewReplicas([]string{"http://deepmock-0", "http://deepmock-1"})
+	assert.NoError(t, err)
+	replicas.client.Dial = func(string) (net.Conn, error) { return ln.Dial() }
+	assert.NoError(t, replicas.WaitForSync(context.Background(), wait))
+
+	replicas, err = NewReplicas([]string{"http://deepmock-0", "http://lagging"})
+	assert.NoError(t, err)
+	replicas.client.Dial = func(string) (net.Conn, error) { return ln.Dial() }
+	assert.EqualError(t, replicas.WaitForSync(context.Background(), wait), "http://lagging: timed out")
+
+	wait.Timeout = "soon"
+	assert.Error(t, replicas.WaitForSync(context.Background(), wait))
+}
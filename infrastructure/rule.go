@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"sort"
+	"time"
 
 	"github.com/didi/gendry/builder"
 	"github.com/didi/gendry/scanner"
@@ -15,18 +17,25 @@ import (
 type (
 	// RuleRepository RuleRepository的MySQL存储实现
 	RuleRepository struct {
-		db    *sql.DB
-		table string
+		db        *sql.DB
+		table     string
+		tombstone string
+		history   string
 	}
 )
 
+// mysqlSyncOverlap 增量同步时向前多查询的时间，mtime只精确到秒，且未提交的事务可能带有更早的mtime
+const mysqlSyncOverlap = 5 * time.Second
+
 func convertRuleEntity(rule *domain.Rule) (*types.RuleDO, error) {
 	do := &types.RuleDO{
-		ID:       rule.ID,
-		Path:     rule.Path,
-		Method:   rule.Method,
-		Version:  rule.Version,
-		Disabled: false,
+		ID:        rule.ID,
+		Namespace: domain.NormalizeNamespace(rule.Namespace),
+		Path:      rule.Path,
+		Method:    rule.Method,
+		Priority:  rule.Priority,
+		Version:   rule.Version,
+		Disabled:  rule.Disabled,
 	}
 	var err error
 	if rule.Variable != nil {
@@ -44,16 +53,25 @@ func convertRuleEntity(rule *domain.Rule) (*types.RuleDO, error) {
 			return nil, err
 		}
 	}
+	if rule.Scenario != nil {
+		if do.Scenario, err = json.Marshal(rule.Scenario); err != nil {
+			return nil, err
+		}
+	}
 	return do, nil
 }
 
 // todo: 现在通过在entity上加tag实现转换，domain层不应该感知infra的数据结构，不合理，之后要优化
 func convertRuleDO(rule *types.RuleDO) (*domain.Rule, error) {
 	entity := &domain.Rule{
-		ID:      rule.ID,
-		Path:    rule.Path,
-		Method:  rule.Method,
-		Version: rule.Version,
+		ID:        rule.ID,
+		Namespace: domain.NormalizeNamespace(rule.Namespace),
+		Path:      rule.Path,
+		Method:    rule.Method,
+		Priority:  rule.Priority,
+		Version:   rule.Version,
+		MTime:     rule.MTime,
+		Disabled:  rule.Disabled,
 	}
 	if rule.Weight != nil {
 		if err := json.Unmarshal(rule.Weight, &entity.Weight); err != nil {
@@ -67,6 +85,12 @@ func convertRuleDO(rule *types.RuleDO) (*domain.Rule, error) {
 		}
 	}
 
+	if rule.Scenario != nil {
+		if err := json.Unmarshal(rule.Scenario, &entity.Scenario); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := json.Unmarshal(rule.Responses, &entity.Regulations); err != nil {
 		return nil, err
 	}
@@ -75,7 +99,7 @@ func convertRuleDO(rule *types.RuleDO) (*domain.Rule, error) {
 
 // NewRuleRepository 工厂函数
 func NewRuleRepository(db *sql.DB) *RuleRepository {
-	return &RuleRepository{db: db, table: "rule"}
+	return &RuleRepository{db: db, table: "rule", tombstone: "rule_tombstone", history: "rule_history"}
 }
 
 // CreateRule 插入新纪录
@@ -99,7 +123,7 @@ func (r *RuleRepository) CreateRule(ctx context.Context, rule *domain.Rule) erro
 	return err
 }
 
-// UpdateRule 更新记录
+// UpdateRule 更新记录，版本号不匹配或规则不存在时返回*domain.VersionConflictError
 func (r *RuleRepository) UpdateRule(ctx context.Context, rule *domain.Rule) error {
 	do, err := convertRuleEntity(rule)
 	if err != nil {
@@ -116,14 +140,28 @@ func (r *RuleRepository) UpdateRule(ctx context.Context, rule *domain.Rule) erro
 			"variable":  do.Variable,
 			"weight":    do.Weight,
 			"responses": do.Responses,
+			"priority":  do.Priority,
+			"scenario":  do.Scenario,
 			"version":   do.Version,
+			"disabled":  do.Disabled,
 		},
 	)
 	if err != nil {
 		return err
 	}
-	_, err = r.db.ExecContext(ctx, cond, values...)
-	return err
+	res, err := r.db.ExecContext(ctx, cond, values...)
+	if err != nil {
+		return err
+	}
+	// 每次更新都会修改version，不存在更新前后值相同而影响0行的情况
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &domain.VersionConflictError{RuleID: do.ID, Expected: do.Version - 1}
+	}
+	return nil
 }
 
 // GetRuleByID 获取记录
@@ -131,9 +169,8 @@ func (r *RuleRepository) GetRuleByID(ctx context.Context, rid string) (*domain.R
 	query, values, _ := builder.BuildSelect(
 		r.table,
 		map[string]interface{}{
-			"id":       rid,
-			"_limit":   []uint{1},
-			"disabled": 0,
+			"id":     rid,
+			"_limit": []uint{1},
 		},
 		[]string{"*"},
 	)
@@ -149,27 +186,67 @@ func (r *RuleRepository) GetRuleByID(ctx context.Context, rid string) (*domain.R
 		return nil, err
 	}
 	if len(rules) == 0 {
-		return nil, errors.New("cannot find rule by id: " + rid)
+		return nil, &domain.RuleNotExistError{RuleID: rid}
 	}
 
 	return convertRuleDO(rules[0])
 }
 
-// DeleteRule 删除记录
-func (r *RuleRepository) DeleteRule(ctx context.Context, rid string) error {
-	cond, values, err := builder.BuildDelete(r.table, map[string]interface{}{"id": rid, "disabled": 0})
+// DeleteRule 删除记录，同时写入删除记录用于增量同步；指定版本号时在同一条DELETE语句中校验
+func (r *RuleRepository) DeleteRule(ctx context.Context, rid string, version int) error {
+	where := map[string]interface{}{"id": rid}
+	if version != domain.AnyVersion {
+		where["version"] = version
+	}
+	cond, values, err := builder.BuildDelete(r.table, where)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, cond, values...)
 	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		_ = tx.Rollback()
+		if err == nil && version != domain.AnyVersion {
+			err = &domain.VersionConflictError{RuleID: rid, Expected: version}
+		}
+		return err
+	}
+
+	if err = r.bury(ctx, tx, rid); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// bury 在事务中写入删除记录，并清理过期的删除记录
+func (r *RuleRepository) bury(ctx context.Context, tx *sql.Tx, rids ...string) error {
+	records := make([]map[string]interface{}, len(rids))
+	for index, rid := range rids {
+		records[index] = map[string]interface{}{"id": rid}
+	}
+	cond, values, _ := builder.BuildReplaceInsert(r.tombstone, records)
+	if _, err := tx.ExecContext(ctx, cond, values...); err != nil {
 		return err
 	}
-	_, err = r.db.ExecContext(ctx, cond, values...)
+	cond, values, _ = builder.BuildDelete(r.tombstone, map[string]interface{}{"dtime <": time.Now().Add(-tombstoneRetention)})
+	_, err := tx.ExecContext(ctx, cond, values...)
 	return err
 }
 
-// Export 导出记录
+// Export 导出记录，包括禁用的规则
 func (r *RuleRepository) Export(ctx context.Context) ([]*domain.Rule, error) {
 	query, values, _ := builder.BuildSelect(
 		r.table,
-		map[string]interface{}{"disabled": 0},
+		map[string]interface{}{},
 		[]string{"*"},
 	)
 	rows, err := r.db.QueryContext(ctx, query, values...)
@@ -194,8 +271,74 @@ func (r *RuleRepository) Export(ctx context.Context) ([]*domain.Rule, error) {
 	return entities, nil
 }
 
+// ExportChanges 导出mtime晚于水位线的规则及删除记录，禁用的规则视为删除
+func (r *RuleRepository) ExportChanges(ctx context.Context, since time.Time) (*domain.RuleChanges, error) {
+	where := map[string]interface{}{}
+	if !since.IsZero() {
+		where["mtime >="] = since.Add(-mysqlSyncOverlap)
+	}
+	query, values, err := builder.BuildSelect(r.table, where, []string{"*"})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []*types.RuleDO
+	if err = scanner.Scan(rows, &rules); err != nil {
+		return nil, err
+	}
+
+	changes := &domain.RuleChanges{Updated: make([]*domain.Rule, 0, len(rules)), Watermark: since}
+	for _, rule := range rules {
+		if rule.MTime.After(changes.Watermark) {
+			changes.Watermark = rule.MTime
+		}
+		if rule.Disabled {
+			changes.Deleted = append(changes.Deleted, rule.ID)
+			continue
+		}
+		entity, err := convertRuleDO(rule)
+		if err != nil {
+			return nil, err
+		}
+		changes.Updated = append(changes.Updated, entity)
+	}
+	if since.IsZero() { // 全量导出时不需要删除记录
+		return changes, nil
+	}
+
+	query, values, err = builder.BuildSelect(r.tombstone, map[string]interface{}{"dtime >=": since.Add(-mysqlSyncOverlap)}, []string{"id", "dtime"})
+	if err != nil {
+		return nil, err
+	}
+	tombRows, err := r.db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer tombRows.Close()
+	var tombstones []*types.RuleTombstoneDO
+	if err = scanner.Scan(tombRows, &tombstones); err != nil {
+		return nil, err
+	}
+	for _, tombstone := range tombstones {
+		if tombstone.DTime.After(changes.Watermark) {
+			changes.Watermark = tombstone.DTime
+		}
+		changes.Deleted = append(changes.Deleted, tombstone.ID)
+	}
+	return changes, nil
+}
+
 // Import 导入记录
 func (r *RuleRepository) Import(ctx context.Context, rules ...*domain.Rule) error {
+	return r.Replace(ctx, rules, nil, nil)
+}
+
+// Replace 在同一个事务中删除指定的规则并导入记录，需要校验版本号的规则先按ID及版本号逐个删除
+func (r *RuleRepository) Replace(ctx context.Context, rules []*domain.Rule, deleted []string, expected map[string]int) error {
 	dataObjects := make([]*types.RuleDO, len(rules))
 	ids := make([]string, len(rules))
 	for index, rule := range rules {
@@ -212,6 +355,44 @@ func (r *RuleRepository) Import(ctx context.Context, rules ...*domain.Rule) erro
 		return err
 	}
 
+	// 按ID排序加锁，避免并发导入时死锁
+	checked := make([]string, 0, len(expected))
+	for rid := range expected {
+		checked = append(checked, rid)
+	}
+	sort.Strings(checked)
+	for _, rid := range checked {
+		cond, values, _ := builder.BuildDelete(r.table, map[string]interface{}{"id": rid, "version": expected[rid]})
+		res, err := tx.ExecContext(ctx, cond, values...)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err == nil && affected == 0 {
+			err = &domain.VersionConflictError{RuleID: rid, Expected: expected[rid]}
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if len(deleted) > 0 {
+		cond, values, _ := builder.BuildDelete(r.table, map[string]interface{}{"id in": deleted})
+		if _, err = tx.ExecContext(ctx, cond, values...); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err = r.bury(ctx, tx, deleted...); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if len(dataObjects) == 0 {
+		return tx.Commit()
+	}
+
 	// 清空存在的记录
 	cond, values, _ := builder.BuildDelete(r.table, map[string]interface{}{
 		"id in": ids,
@@ -246,3 +427,96 @@ func (r *RuleRepository) Import(ctx context.Context, rules ...*domain.Rule) erro
 	}
 	return tx.Commit()
 }
+
+// SaveRevision 保存历史版本，在事务中锁定该规则最新的历史版本后分配版本号
+func (r *RuleRepository) SaveRevision(ctx context.Context, rev *domain.RuleRevision) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	query, values, _ := builder.BuildSelect(
+		r.history,
+		map[string]interface{}{"rule_id": rev.RuleID, "_orderby": "revision desc", "_limit": []uint{1}},
+		[]string{"revision"},
+	)
+	var latest int
+	err = tx.QueryRowContext(ctx, query+" FOR UPDATE", values...).Scan(&latest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return err
+	}
+
+	rev.Revision = latest + 1
+	rev.CTime = time.Now()
+	record, err := newRevisionRecord(rev)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	cond, values, err := builder.BuildInsert(r.history, []map[string]interface{}{{
+		"rule_id":  record.RuleID,
+		"revision": record.Revision,
+		"action":   record.Action,
+		"author":   record.Author,
+		"content":  []byte(record.Rule),
+		"ctime":    record.CTime,
+	}})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, cond, values...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *RuleRepository) queryRevisions(ctx context.Context, where map[string]interface{}) ([]*domain.RuleRevision, error) {
+	query, values, err := builder.BuildSelect(r.history, where, []string{"*"})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dataObjects []*types.RuleRevisionDO
+	if err = scanner.Scan(rows, &dataObjects); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*domain.RuleRevision, len(dataObjects))
+	for index, do := range dataObjects {
+		record := &revisionRecord{
+			RuleID:   do.RuleID,
+			Revision: do.Revision,
+			Action:   do.Action,
+			Author:   do.Author,
+			CTime:    do.CTime,
+			Rule:     do.Content,
+		}
+		if revisions[index], err = record.entity(); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// ListRevisions 按版本号升序返回规则的所有历史版本
+func (r *RuleRepository) ListRevisions(ctx context.Context, rid string) ([]*domain.RuleRevision, error) {
+	return r.queryRevisions(ctx, map[string]interface{}{"rule_id": rid, "_orderby": "revision asc"})
+}
+
+// GetRevision 获取指定的历史版本
+func (r *RuleRepository) GetRevision(ctx context.Context, rid string, revision int) (*domain.RuleRevision, error) {
+	revisions, err := r.queryRevisions(ctx, map[string]interface{}{"rule_id": rid, "revision": revision})
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, revisionNotFound(rid, revision)
+	}
+	return revisions[0], nil
+}
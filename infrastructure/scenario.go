@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/wosai/deepmock/domain"
+)
+
+type (
+	// ScenarioRepository ScenarioRepository的内存存储库实现，场景状态按命名空间分别记录
+	ScenarioRepository struct {
+		states map[string]map[string]string
+		mu     sync.RWMutex
+	}
+)
+
+// NewScenarioRepository 工厂函数
+func NewScenarioRepository() *ScenarioRepository {
+	return &ScenarioRepository{states: map[string]map[string]string{}}
+}
+
+// GetState 获取命名空间中场景的当前状态，未记录的场景处于初始状态
+func (sr *ScenarioRepository) GetState(_ context.Context, namespace, name string) string {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	return sr.state(namespace, name)
+}
+
+// CompareAndSet 命名空间中所有场景的当前状态都满足要求时才一并切换，否则不做任何修改并返回false
+func (sr *ScenarioRepository) CompareAndSet(_ context.Context, namespace string, transitions ...domain.ScenarioTransition) bool {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	for _, transition := range transitions {
+		if transition.Expected != "" && sr.state(namespace, transition.Name) != transition.Expected {
+			return false
+		}
+	}
+	for _, transition := range transitions {
+		if transition.State == "" {
+			continue
+		}
+		states, exists := sr.states[namespace]
+		if !exists {
+			states = map[string]string{}
+			sr.states[namespace] = states
+		}
+		states[transition.Name] = transition.State
+	}
+	return true
+}
+
+func (sr *ScenarioRepository) state(namespace, name string) string {
+	if state, exists := sr.states[namespace][name]; exists {
+		return state
+	}
+	return domain.ScenarioStateStarted
+}
+
+// ListStates 返回命名空间中所有被记录的场景状态
+func (sr *ScenarioRepository) ListStates(_ context.Context, namespace string) map[string]string {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+
+	states := make(map[string]string, len(sr.states[namespace]))
+	for k, v := range sr.states[namespace] {
+		states[k] = v
+	}
+	return states
+}
+
+// Reset 将命名空间中指定的场景恢复到初始状态，未指定时重置该命名空间的所有场景
+func (sr *ScenarioRepository) Reset(_ context.Context, namespace string, names ...string) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if len(names) == 0 {
+		delete(sr.states, namespace)
+		return
+	}
+	for _, name := range names {
+		delete(sr.states[namespace], name)
+	}
+}
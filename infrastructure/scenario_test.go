@@ -0,0 +1,71 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/domain"
+)
+
+func TestScenarioRepository(t *testing.T) {
+	repo := NewScenarioRepository()
+	assert.Equal(t, domain.ScenarioStateStarted, repo.GetState(context.TODO(), domain.DefaultNamespace, "payment"))
+
+	assert.True(t, repo.CompareAndSet(context.TODO(), domain.DefaultNamespace, domain.ScenarioTransition{Name: "payment", State: "paid"}))
+	assert.True(t, repo.CompareAndSet(context.TODO(), domain.DefaultNamespace, domain.ScenarioTransition{Name: "refund", Expected: domain.ScenarioStateStarted, State: "refunded"}))
+	assert.Equal(t, map[string]string{"payment": "paid", "refund": "refunded"}, repo.ListStates(context.TODO(), domain.DefaultNamespace))
+
+	repo.Reset(context.TODO(), domain.DefaultNamespace, "payment")
+	assert.Equal(t, domain.ScenarioStateStarted, repo.GetState(context.TODO(), domain.DefaultNamespace, "payment"))
+	assert.Equal(t, "refunded", repo.GetState(context.TODO(), domain.DefaultNamespace, "refund"))
+
+	repo.Reset(context.TODO(), domain.DefaultNamespace)
+	assert.Empty(t, repo.ListStates(context.TODO(), domain.DefaultNamespace))
+}
+
+func TestScenarioRepository_CompareAndSet(t *testing.T) {
+	repo := NewScenarioRepository()
+	ctx := context.TODO()
+
+	// 任意一个场景不满足要求时都不切换
+	assert.False(t, repo.CompareAndSet(ctx, domain.DefaultNamespace,
+		domain.ScenarioTransition{Name: "payment", Expected: domain.ScenarioStateStarted, State: "paid"},
+		domain.ScenarioTransition{Name: "refund", Expected: "refunding"},
+	))
+	assert.Empty(t, repo.ListStates(ctx, domain.DefaultNamespace))
+
+	var wg sync.WaitGroup
+	var succeeded int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if repo.CompareAndSet(ctx, domain.DefaultNamespace, domain.ScenarioTransition{Name: "payment", Expected: domain.ScenarioStateStarted, State: "paid"}) {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, succeeded)
+	assert.Equal(t, "paid", repo.GetState(ctx, domain.DefaultNamespace, "payment"))
+}
+
+func TestScenarioRepository_Namespace(t *testing.T) {
+	repo := NewScenarioRepository()
+	ctx := context.TODO()
+
+	// 不同命名空间中的同名场景互不影响
+	assert.True(t, repo.CompareAndSet(ctx, "payment", domain.ScenarioTransition{Name: "order", State: "paid"}))
+	assert.True(t, repo.CompareAndSet(ctx, "refund", domain.ScenarioTransition{Name: "order", Expected: domain.ScenarioStateStarted, State: "refunded"}))
+	assert.Equal(t, "paid", repo.GetState(ctx, "payment", "order"))
+	assert.Equal(t, "refunded", repo.GetState(ctx, "refund", "order"))
+	assert.Equal(t, domain.ScenarioStateStarted, repo.GetState(ctx, domain.DefaultNamespace, "order"))
+	assert.Equal(t, map[string]string{"order": "paid"}, repo.ListStates(ctx, "payment"))
+
+	repo.Reset(ctx, "payment")
+	assert.Empty(t, repo.ListStates(ctx, "payment"))
+	assert.Equal(t, map[string]string{"order": "refunded"}, repo.ListStates(ctx, "refund"))
+}
@@ -0,0 +1,210 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/misc"
+	"github.com/wosai/deepmock/option"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	// TracingExporterNone 不开启链路追踪
+	TracingExporterNone = "none"
+	// TracingExporterOTLP 通过OTLP/HTTP导出
+	TracingExporterOTLP = "otlp"
+	// TracingExporterStdout 输出到标准输出
+	TracingExporterStdout = "stdout"
+	// TracingExporterFile 输出到文件
+	TracingExporterFile = "file"
+)
+
+type (
+	// TracedRuleRepository 为RuleRepository的调用记录Span，只在上下文中已有Span时生效
+	TracedRuleRepository struct {
+		rule   domain.RuleRepository
+		system string
+	}
+
+	// fileExporter 关闭导出器时一并关闭文件
+	fileExporter struct {
+		sdktrace.SpanExporter
+		file io.Closer
+	}
+)
+
+// NewSpanExporter 根据配置创建Span导出器，未开启时返回nil
+func NewSpanExporter(opt option.TracingOption) (sdktrace.SpanExporter, error) {
+	switch opt.Exporter {
+	case "", TracingExporterNone:
+		return nil, nil
+
+	case TracingExporterOTLP:
+		return NewOTLPExporter(opt)
+
+	case TracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+	case TracingExporterFile:
+		if opt.File == "" {
+			return nil, errors.New("missing file path of tracing exporter")
+		}
+		f, err := os.OpenFile(opt.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, file: f}, nil
+
+	default:
+		return nil, errors.New("unsupported tracing exporter: " + opt.Exporter)
+	}
+}
+
+// NewOTLPExporter 创建OTLP/HTTP导出器，Endpoint为完整的collector地址，如 http://localhost:4318/v1/traces
+func NewOTLPExporter(opt option.TracingOption) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(opt.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, errors.New("bad tracing endpoint: " + opt.Endpoint)
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host), otlptracehttp.WithURLPath(u.Path)}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if opt.Timeout > 0 {
+		options = append(options, otlptracehttp.WithTimeout(opt.Timeout))
+	}
+	headers := map[string]string{}
+	for _, header := range opt.Headers {
+		kv := strings.SplitN(header, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.New("bad tracing header: " + header)
+		}
+		headers[kv[0]] = kv[1]
+	}
+	if len(headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(headers))
+	}
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+// Shutdown 关闭导出器及文件
+func (fe *fileExporter) Shutdown(ctx context.Context) error {
+	err := fe.SpanExporter.Shutdown(ctx)
+	if e := fe.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// NewTracedRuleRepository 工厂函数，system为存储后端的名称，如mysql
+func NewTracedRuleRepository(rr domain.RuleRepository, system string) *TracedRuleRepository {
+	return &TracedRuleRepository{rule: rr, system: system}
+}
+
+func (tr *TracedRuleRepository) startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() { // 同步任务等后台调用不记录
+		return ctx, noop.Span{}
+	}
+	return misc.Tracer().Start(ctx, "RuleRepository."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", tr.system), attribute.String("db.operation", operation)))
+}
+
+// CreateRule 插入新纪录
+func (tr *TracedRuleRepository) CreateRule(ctx context.Context, rule *domain.Rule) error {
+	ctx, span := tr.startSpan(ctx, "CreateRule")
+	span.SetAttributes(attribute.String("deepmock.rule_id", rule.ID))
+	err := tr.rule.CreateRule(ctx, rule)
+	misc.FinishSpan(span, err)
+	return err
+}
+
+// UpdateRule 更新记录
+func (tr *TracedRuleRepository) UpdateRule(ctx context.Context, rule *domain.Rule) error {
+	ctx, span := tr.startSpan(ctx, "UpdateRule")
+	span.SetAttributes(attribute.String("deepmock.rule_id", rule.ID))
+	err := tr.rule.UpdateRule(ctx, rule)
+	misc.FinishSpan(span, err)
+	return err
+}
+
+// GetRuleByID 获取记录
+func (tr *TracedRuleRepository) GetRuleByID(ctx context.Context, rid string) (*domain.Rule, error) {
+	ctx, span := tr.startSpan(ctx, "GetRuleByID")
+	span.SetAttributes(attribute.String("deepmock.rule_id", rid))
+	rule, err := tr.rule.GetRuleByID(ctx, rid)
+	misc.FinishSpan(span, err)
+	return rule, err
+}
+
+// DeleteRule 删除记录
+func (tr *TracedRuleRepository) DeleteRule(ctx context.Context, rid string, version int) error {
+	ctx, span := tr.startSpan(ctx, "DeleteRule")
+	span.SetAttributes(attribute.String("deepmock.rule_id", rid))
+	err := tr.rule.DeleteRule(ctx, rid, version)
+	misc.FinishSpan(span, err)
+	return err
+}
+
+// Export 导出记录
+func (tr *TracedRuleRepository) Export(ctx context.Context) ([]*domain.Rule, error) {
+	ctx, span := tr.startSpan(ctx, "Export")
+	rules, err := tr.rule.Export(ctx)
+	span.SetAttributes(attribute.Int("deepmock.rules", len(rules)))
+	misc.FinishSpan(span, err)
+	return rules, err
+}
+
+// ExportChanges 增量导出记录，被装饰的存储库不支持增量导出时返回ErrIncrementalSyncUnsupported
+func (tr *TracedRuleRepository) ExportChanges(ctx context.Context, since time.Time) (*domain.RuleChanges, error) {
+	ir, ok := tr.rule.(domain.IncrementalRuleRepository)
+	if !ok {
+		return nil, ErrIncrementalSyncUnsupported
+	}
+	ctx, span := tr.startSpan(ctx, "ExportChanges")
+	changes, err := ir.ExportChanges(ctx, since)
+	if changes != nil {
+		span.SetAttributes(attribute.Int("deepmock.rules", len(changes.Updated)))
+		span.SetAttributes(attribute.Int("deepmock.deleted_rules", len(changes.Deleted)))
+	}
+	misc.FinishSpan(span, err)
+	return changes, err
+}
+
+// Import 导入记录
+func (tr *TracedRuleRepository) Import(ctx context.Context, rules ...*domain.Rule) error {
+	ctx, span := tr.startSpan(ctx, "Import")
+	span.SetAttributes(attribute.Int("deepmock.rules", len(rules)))
+	err := tr.rule.Import(ctx, rules...)
+	misc.FinishSpan(span, err)
+	return err
+}
+
+// Replace 导入记录并删除指定的规则
+func (tr *TracedRuleRepository) Replace(ctx context.Context, rules []*domain.Rule, deleted []string, expected map[string]int) error {
+	ctx, span := tr.startSpan(ctx, "Replace")
+	span.SetAttributes(attribute.Int("deepmock.rules", len(rules)), attribute.Int("deepmock.deleted_rules", len(deleted)))
+	err := tr.rule.Replace(ctx, rules, deleted, expected)
+	misc.FinishSpan(span, err)
+	return err
+}
@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wosai/deepmock/option"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func exportTestSpan(t *testing.T, exporter sdktrace.SpanExporter) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	_, span := tp.Tracer("test").Start(context.Background(), "mock GET")
+	span.End()
+	assert.NoError(t, tp.Shutdown(context.Background()))
+}
+
+func TestNewSpanExporter(t *testing.T) {
+	exporter, err := NewSpanExporter(option.TracingOption{Exporter: TracingExporterNone})
+	assert.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = NewSpanExporter(option.TracingOption{Exporter: "jaeger"})
+	assert.Error(t, err)
+	_, err = NewSpanExporter(option.TracingOption{Exporter: TracingExporterFile})
+	assert.Error(t, err)
+	_, err = NewSpanExporter(option.TracingOption{Exporter: TracingExporterOTLP, Endpoint: "localhost:4318"})
+	assert.Error(t, err)
+	_, err = NewSpanExporter(option.TracingOption{Exporter: TracingExporterOTLP, Endpoint: "http://localhost:4318/v1/traces", Headers: []string{"token"}})
+	assert.Error(t, err)
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	exporter, err := NewSpanExporter(option.TracingOption{Exporter: TracingExporterFile, File: path})
+	assert.NoError(t, err)
+	exportTestSpan(t, exporter)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"mock GET"`)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		received <- r
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	exporter, err := NewSpanExporter(option.TracingOption{
+		Exporter: TracingExporterOTLP,
+		Endpoint: srv.URL + "/collector/v1/traces",
+		Headers:  []string{"Authorization=Bearer token"},
+		Timeout:  time.Second,
+	})
+	assert.NoError(t, err)
+	exportTestSpan(t, exporter)
+
+	r := <-received
+	assert.Equal(t, "/collector/v1/traces", r.URL.Path)
+	assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+	assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(r.Body)
+	assert.Contains(t, string(body), "mock GET")
+}
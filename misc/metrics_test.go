@@ -0,0 +1,32 @@
+package misc
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	hits := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_hits_total", Help: "Number of hits."}, []string{"rule_id"})
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_latency_seconds", Help: "Latency.", Buckets: []float64{0.1, 1}}, []string{"rule_id"})
+	registry.MustRegister(hits, latency)
+
+	hits.WithLabelValues(`a"b`).Inc()
+	latency.WithLabelValues("a").Observe(0.05)
+	latency.WithLabelValues("a").Observe(5)
+
+	ctx := new(fasthttp.RequestCtx)
+	MetricsHandler(registry)(ctx)
+	out := string(ctx.Response.Body())
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Header.ContentType()), "text/plain")
+	assert.Contains(t, out, "# TYPE test_hits_total counter\n")
+	assert.Contains(t, out, `test_hits_total{rule_id="a\"b"} 1`+"\n")
+	assert.Contains(t, out, `test_latency_seconds_bucket{rule_id="a",le="0.1"} 1`+"\n")
+	assert.Contains(t, out, `test_latency_seconds_bucket{rule_id="a",le="+Inf"} 2`+"\n")
+	assert.Contains(t, out, `test_latency_seconds_count{rule_id="a"} 2`+"\n")
+	assert.NotContains(t, out, "deepmock_")
+}
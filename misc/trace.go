@@ -0,0 +1,106 @@
+package misc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HeaderTraceParent W3C Trace Context的请求头
+	HeaderTraceParent = "traceparent"
+
+	instrumentationName = "github.com/wosai/deepmock"
+)
+
+type (
+	// requestHeaderCarrier 以fasthttp请求头实现propagation.TextMapCarrier
+	requestHeaderCarrier struct {
+		header *fasthttp.RequestHeader
+	}
+)
+
+var (
+	// propagator 只传播W3C Trace Context，不依赖全局设置
+	propagator = propagation.TraceContext{}
+
+	provider   *sdktrace.TracerProvider
+	providerMu sync.Mutex
+)
+
+// Get 获取请求头
+func (c requestHeaderCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+// Set 设置请求头
+func (c requestHeaderCarrier) Set(key, value string) {
+	c.header.Set(key, value)
+}
+
+// Keys 返回所有请求头的名称
+func (c requestHeaderCarrier) Keys() []string {
+	var keys []string
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// Tracer 返回服务的Tracer，未开启链路追踪时Span不会被记录
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// ExtractTraceContext 将请求头中的链路上下文作为后续Span的父节点
+func ExtractTraceContext(ctx context.Context, header *fasthttp.RequestHeader) context.Context {
+	return propagator.Extract(ctx, requestHeaderCarrier{header: header})
+}
+
+// InjectTraceContext 将上下文中的链路信息写入请求头，用于向下游传播
+func InjectTraceContext(ctx context.Context, header *fasthttp.RequestHeader) {
+	propagator.Inject(ctx, requestHeaderCarrier{header: header})
+}
+
+// FinishSpan 记录错误并结束Span
+func FinishSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetSpanExporter 开启链路追踪，Span批量提交给导出器；需要在服务启动前调用
+func SetSpanExporter(exporter sdktrace.SpanExporter, service string) {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+	if err != nil {
+		res = resource.Default()
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	provider = tp
+	otel.SetTracerProvider(tp)
+}
+
+// ShutdownTracing 导出剩余的Span并关闭导出器
+func ShutdownTracing(ctx context.Context) error {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	provider = nil
+	return err
+}
@@ -0,0 +1,76 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// keepingExporter 关闭时保留已导出的Span
+type keepingExporter struct {
+	*tracetest.InMemoryExporter
+	shutdown bool
+}
+
+func (ke *keepingExporter) Shutdown(_ context.Context) error {
+	ke.shutdown = true
+	return nil
+}
+
+func TestTraceContext(t *testing.T) {
+	var in, out fasthttp.RequestHeader
+	in.Set(HeaderTraceParent, testTraceParent)
+	ctx := ExtractTraceContext(context.Background(), &in)
+	sc := trace.SpanContextFromContext(ctx)
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+
+	InjectTraceContext(ctx, &out)
+	assert.Equal(t, testTraceParent, string(out.Peek(HeaderTraceParent)))
+
+	in.Set(HeaderTraceParent, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	assert.False(t, trace.SpanContextFromContext(ExtractTraceContext(context.Background(), &in)).IsValid())
+}
+
+func TestSetSpanExporter(t *testing.T) {
+	exporter := &keepingExporter{InMemoryExporter: tracetest.NewInMemoryExporter()}
+	SetSpanExporter(exporter, "deepmock-test")
+
+	var header fasthttp.RequestHeader
+	header.Set(HeaderTraceParent, testTraceParent)
+	ctx, server := Tracer().Start(ExtractTraceContext(context.Background(), &header), "server", trace.WithSpanKind(trace.SpanKindServer))
+	_, client := Tracer().Start(ctx, "client", trace.WithSpanKind(trace.SpanKindClient))
+	FinishSpan(client, errors.New("boom"))
+	FinishSpan(server, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, ShutdownTracing(ctx))
+	assert.NoError(t, ShutdownTracing(ctx))
+	assert.True(t, exporter.shutdown)
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "client", spans[0].Name)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t, "boom", spans[0].Status.Description)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+
+		assert.Equal(t, "server", spans[1].Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent.SpanID().String())
+		assert.Equal(t, codes.Unset, spans[1].Status.Code)
+		v, ok := spans[1].Resource.Set().Value("service.name")
+		assert.True(t, ok)
+		assert.Equal(t, "deepmock-test", v.AsString())
+	}
+}
@@ -3,9 +3,18 @@ package misc
 import (
 	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGenID(t *testing.T) {
 	fmt.Println(GenID([]byte("/rpc/token"), []byte("POST")))
 	fmt.Println(GenID([]byte("^/$"), []byte("GET")))
 }
+
+func TestGenNamespacedID(t *testing.T) {
+	id := GenNamespacedID([]byte("payment"), []byte("/rpc/token"), []byte("post"))
+	assert.Equal(t, id, GenNamespacedID([]byte("payment"), []byte("/rpc/token"), []byte("POST")))
+	assert.NotEqual(t, id, GenNamespacedID([]byte("order"), []byte("/rpc/token"), []byte("POST")))
+	assert.NotEqual(t, id, GenID([]byte("/rpc/token"), []byte("POST")))
+}
@@ -28,6 +28,8 @@ const (
 var (
 	defaultHashPoll *hashPool
 	salt            = []byte(`6ee30676-6c88-4d3a-86b1-bb61e82da1c9`)
+
+	namespaceDelimiter = []byte{0}
 )
 
 func newHashPool() *hashPool {
@@ -57,6 +59,19 @@ func GenID(path, method []byte) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// GenNamespacedID 带命名空间的GenID，同一path与method在不同命名空间中的ID不同
+func GenNamespacedID(namespace, path, method []byte) string {
+	h := defaultHashPoll.get()
+	defer defaultHashPoll.put(h)
+
+	h.Write(namespace)
+	h.Write(namespaceDelimiter)
+	h.Write(bytes.ToUpper(method))
+	h.Write(path)
+	h.Write(salt)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // GenRandomString 生产指定长度的随机字符串
 func GenRandomString(n int) string {
 	b := make([]byte, n)
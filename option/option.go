@@ -1,9 +1,18 @@
 package option
 
+import "time"
+
 type (
 	Option struct {
-		Server ServerOption
-		DB     DatabaseOption
+		Server      ServerOption
+		DB          DatabaseOption
+		Storage     StorageOption
+		Sync        SyncOption
+		Proxy       ProxyOption
+		Namespace   NamespaceOption
+		Journal     JournalOption
+		Diagnostics DiagnosticsOption
+		Tracing     TracingOption
 	}
 
 	DatabaseOption struct {
@@ -15,9 +24,51 @@ type (
 		ConnectRetry int    `default:"3" yaml:"connect_retry" json:"connect_retry"` // 解决istio启动的问题
 	}
 
+	StorageOption struct {
+		Driver   string `default:"mysql"`                                     // 规则存储后端：mysql/memory/file/bolt
+		Snapshot string `yaml:"snapshot,omitempty" json:"snapshot,omitempty"` // memory后端的快照文件，启动时载入、关闭时保存，为空时不保存
+		Dir      string `yaml:"dir,omitempty" json:"dir,omitempty"`           // file后端的规则文件目录
+		ReadOnly bool   `yaml:"read_only" json:"read_only"`                   // file后端只读，拒绝通过管理接口修改规则
+		Path     string `yaml:"path,omitempty" json:"path,omitempty"`         // bolt后端的数据库文件路径
+	}
+
+	SyncOption struct {
+		Period     time.Duration `default:"2s"`                                        // 规则同步周期
+		FullPeriod time.Duration `default:"10m" yaml:"full_period" json:"full_period"` // 存储库支持增量同步时，全量同步的周期，为0时只在启动时全量同步
+		Peers      []string      `yaml:"peers,omitempty" json:"peers,omitempty"`       // 多实例部署时各实例的地址，用于等待所有实例完成同步
+	}
+
 	ServerOption struct {
 		Port     string `default:":16600"`
 		KeyFile  string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
 		CertFile string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
 	}
+
+	ProxyOption struct {
+		Upstream string        `yaml:"upstream,omitempty" json:"upstream,omitempty"` // 全局上游地址，如 http://staging:8080
+		Routes   []string      `yaml:"routes,omitempty" json:"routes,omitempty"`     // 按路径前缀转发，格式为 /prefix=http://host:port
+		Timeout  time.Duration `default:"10s" yaml:"timeout" json:"timeout"`         // 转发超时时间
+	}
+
+	NamespaceOption struct {
+		Hosts    []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`       // 按Host选择mock请求的命名空间，格式为 host=namespace
+		Prefixes []string `yaml:"prefixes,omitempty" json:"prefixes,omitempty"` // 按路径前缀选择mock请求的命名空间，格式为 /prefix=namespace，去掉前缀后再匹配规则
+	}
+
+	JournalOption struct {
+		Size int `default:"1000"` // 最多保留的请求日志条数
+	}
+
+	DiagnosticsOption struct {
+		Enabled bool // 关闭时也可以通过请求头 X-Deepmock-Debug 对单个请求开启
+	}
+
+	TracingOption struct {
+		Exporter    string        `default:"none"`                                             // none/otlp/stdout/file
+		Endpoint    string        `default:"http://localhost:4318/v1/traces"`                  // OTLP/HTTP地址
+		Headers     []string      `yaml:"headers,omitempty" json:"headers,omitempty"`          // 请求OTLP时附带的请求头，格式为 key=value
+		File        string        `yaml:"file,omitempty" json:"file,omitempty"`                // file导出器的文件路径
+		ServiceName string        `default:"deepmock" yaml:"service_name" json:"service_name"` // 资源属性service.name
+		Timeout     time.Duration `default:"10s" yaml:"timeout" json:"timeout"`
+	}
 )
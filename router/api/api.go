@@ -6,17 +6,30 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/valyala/fasthttp"
 	"github.com/wosai/deepmock/application"
+	"github.com/wosai/deepmock/domain"
 	"github.com/wosai/deepmock/misc"
 	"github.com/wosai/deepmock/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 var (
 	slash          = []byte(`/`)
 	apiGetRulePath = []byte(`/api/v1/rule`)
+	metricsHandler = misc.MetricsHandler(prometheus.DefaultGatherer)
+)
+
+const (
+	traceContextKey = "deepmock.trace_context"
+	headerUser      = "X-Deepmock-User"
 )
 
 func parsePathVar(path, uri []byte) string {
@@ -31,10 +44,49 @@ func parsePathVar(path, uri []byte) string {
 	return ""
 }
 
+// HandleTracing 为管理接口记录Span的中间件，需要在所有管理接口之前注册
+func HandleTracing(ctx *fasthttp.RequestCtx, next func(error)) {
+	c, span := misc.Tracer().Start(misc.ExtractTraceContext(context.Background(), &ctx.Request.Header), string(ctx.Method())+" "+string(ctx.Path()), trace.WithSpanKind(trace.SpanKindServer))
+	if !span.IsRecording() {
+		next(nil)
+		return
+	}
+
+	ctx.SetUserValue(traceContextKey, c)
+	next(nil)
+	span.SetAttributes(
+		attribute.String("http.method", string(ctx.Method())),
+		attribute.String("http.target", string(ctx.Path())),
+		attribute.Int("http.status_code", ctx.Response.StatusCode()),
+	)
+	span.End()
+}
+
+// requestContext 返回携带链路信息及命名空间的上下文，命名空间取自查询参数namespace或者请求头X-Deepmock-Namespace
+func requestContext(ctx *fasthttp.RequestCtx) context.Context {
+	c, ok := ctx.UserValue(traceContextKey).(context.Context)
+	if !ok {
+		c = context.Background()
+	}
+	namespace := ctx.QueryArgs().Peek("namespace")
+	if len(namespace) == 0 {
+		namespace = ctx.Request.Header.Peek(application.HeaderNamespace)
+	}
+	if len(namespace) == 0 {
+		return c
+	}
+	return application.ContextWithNamespace(c, string(namespace))
+}
+
 // HandleMockedAPI 处理所有mock api
 func HandleMockedAPI(ctx *fasthttp.RequestCtx, _ func(error)) {
 	err := application.MockApplication.MockAPI(ctx)
 	if err != nil {
+		var unmatched *application.UnmatchedError
+		if errors.As(err, &unmatched) {
+			renderFailedAPIResponseWithData(&ctx.Response, err, unmatched.Diagnosis)
+			return
+		}
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
@@ -47,29 +99,33 @@ func HandleCreateRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 		return
 	}
 
-	rid, err := application.MockApplication.CreateRule(context.TODO(), rule)
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	rid, err := application.MockApplication.CreateRule(c, rule)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	rule, err = application.MockApplication.GetRule(context.TODO(), rid)
+	rule, err = application.MockApplication.GetRule(requestContext(ctx), rid)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	renderSuccessfulResponse(&ctx.Response, rule)
+	renderRule(&ctx.Response, rule)
 }
 
 // HandleGetRule 根据rule id获取规则
 func HandleGetRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 	ruleID := parsePathVar(apiGetRulePath, ctx.RequestURI())
 
-	rule, err := application.MockApplication.GetRule(context.TODO(), ruleID)
+	rule, err := application.MockApplication.GetRule(requestContext(ctx), ruleID)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	renderSuccessfulResponse(&ctx.Response, rule)
+	renderRule(&ctx.Response, rule)
 }
 
 // HandleDeleteRule 根据rule id删除规则
@@ -79,7 +135,11 @@ func HandleDeleteRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 		return
 	}
 
-	err := application.MockApplication.DeleteRule(context.TODO(), res.ID)
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	err = application.MockApplication.DeleteRule(c, res.ID)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
@@ -87,6 +147,38 @@ func HandleDeleteRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 	renderSuccessfulResponse(&ctx.Response, nil)
 }
 
+// HandleDisableRule 根据rule id禁用规则，禁用后请求将回落到其他规则
+func HandleDisableRule(ctx *fasthttp.RequestCtx, _ func(error)) {
+	handleSetRuleDisabled(ctx, application.MockApplication.DisableRule)
+}
+
+// HandleEnableRule 根据rule id启用规则
+func HandleEnableRule(ctx *fasthttp.RequestCtx, _ func(error)) {
+	handleSetRuleDisabled(ctx, application.MockApplication.EnableRule)
+}
+
+func handleSetRuleDisabled(ctx *fasthttp.RequestCtx, set func(context.Context, string) error) {
+	res := new(types.RuleDTO)
+	if err := bindBody(ctx, res); err != nil {
+		return
+	}
+
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	if err = set(c, res.ID); err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	rule, err := application.MockApplication.GetRule(requestContext(ctx), res.ID)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderRule(&ctx.Response, rule)
+}
+
 // HandlePutRule 根据rule id更新目前规则，如果规则不存在，不会新建
 func HandlePutRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 	res := new(types.RuleDTO)
@@ -94,17 +186,21 @@ func HandlePutRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 		return
 	}
 
-	err := application.MockApplication.PutRule(context.TODO(), res)
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	err = application.MockApplication.PutRule(c, res)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	rule, err := application.MockApplication.GetRule(context.TODO(), res.ID)
+	rule, err := application.MockApplication.GetRule(requestContext(ctx), res.ID)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	renderSuccessfulResponse(&ctx.Response, rule)
+	renderRule(&ctx.Response, rule)
 }
 
 // HandlePatchRule 根据rule id更新目前规则，与put的区别在于：put需要传入完整的rule对象，而patch只需要传入更新部分即可
@@ -114,22 +210,108 @@ func HandlePatchRule(ctx *fasthttp.RequestCtx, _ func(error)) {
 		return
 	}
 
-	err := application.MockApplication.PatchRule(context.TODO(), res)
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	err = application.MockApplication.PatchRule(c, res)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	rule, err := application.MockApplication.GetRule(requestContext(ctx), res.ID)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	rule, err := application.MockApplication.GetRule(context.TODO(), res.ID)
+	renderRule(&ctx.Response, rule)
+}
+
+// HandleWaitForSync 等待本实例完成指定规则版本的同步，由修改规则的实例调用
+func HandleWaitForSync(ctx *fasthttp.RequestCtx, _ func(error)) {
+	wait := new(types.SyncWaitDTO)
+	if err := bindBody(ctx, wait); err != nil {
+		return
+	}
+
+	if err := application.MockApplication.WaitForSync(requestContext(ctx), wait); err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, nil)
+}
+
+// HandleListRevisions 查询规则的所有历史版本
+func HandleListRevisions(ctx *fasthttp.RequestCtx, _ func(error)) {
+	revisions, err := application.MockApplication.ListRevisions(requestContext(ctx), string(ctx.QueryArgs().Peek("rule_id")))
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, revisions)
+}
+
+// HandleGetRevision 获取规则的指定历史版本
+func HandleGetRevision(ctx *fasthttp.RequestCtx, _ func(error)) {
+	revision, err := ctx.QueryArgs().GetUint("revision")
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	rev, err := application.MockApplication.GetRevision(requestContext(ctx), string(ctx.QueryArgs().Peek("rule_id")), revision)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, rev)
+}
+
+// HandleDiffRevisions 比较规则的两个历史版本
+func HandleDiffRevisions(ctx *fasthttp.RequestCtx, _ func(error)) {
+	from, err := ctx.QueryArgs().GetUint("from")
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	to, err := ctx.QueryArgs().GetUint("to")
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	diff, err := application.MockApplication.DiffRevisions(requestContext(ctx), string(ctx.QueryArgs().Peek("rule_id")), from, to)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, diff)
+}
+
+// HandleRollback 将规则回滚至指定的历史版本
+func HandleRollback(ctx *fasthttp.RequestCtx, _ func(error)) {
+	rb := new(types.RollbackDTO)
+	if err := bindBody(ctx, rb); err != nil {
+		return
+	}
+
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	if err = application.MockApplication.Rollback(c, rb); err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	rule, err := application.MockApplication.GetRule(requestContext(ctx), rb.RuleID)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
-	renderSuccessfulResponse(&ctx.Response, rule)
+	renderRule(&ctx.Response, rule)
 }
 
-// HandleExportRules 导出当前所有规则
+// HandleExportRules 导出当前所有规则，查询参数include_disabled=true时包括禁用的规则
 func HandleExportRules(ctx *fasthttp.RequestCtx, _ func(error)) {
-	rules, err := application.MockApplication.Export(context.TODO())
+	rules, err := application.MockApplication.Export(requestContext(ctx), ctx.QueryArgs().GetBool("include_disabled"))
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
@@ -137,26 +319,238 @@ func HandleExportRules(ctx *fasthttp.RequestCtx, _ func(error)) {
 	renderSuccessfulResponse(&ctx.Response, rules)
 }
 
-// HandleImportRules 导入规则，将会清空目前所有规则
+// HandleImportRules 导入规则，查询参数mode指定导入模式，默认为upsert；dry_run=true时只返回导入计划
 func HandleImportRules(ctx *fasthttp.RequestCtx, _ func(error)) {
 	var rules []*types.RuleDTO
 	if err := bindBody(ctx, &rules); err != nil {
 		return
 	}
 
-	err := application.MockApplication.Import(context.TODO(), rules...)
+	c, err := writeContext(ctx)
+	if err != nil {
+		return
+	}
+	args := ctx.QueryArgs()
+	plan, err := application.MockApplication.Import(c, string(args.Peek("mode")), args.GetBool("dry_run"), rules...)
 	if err != nil {
 		renderFailedAPIResponse(&ctx.Response, err)
 		return
 	}
+	renderSuccessfulResponse(&ctx.Response, plan)
+}
+
+// HandleListScenarios 查询所有场景的当前状态
+func HandleListScenarios(ctx *fasthttp.RequestCtx, _ func(error)) {
+	renderSuccessfulResponse(&ctx.Response, application.MockApplication.ListScenarios(requestContext(ctx)))
+}
+
+// HandleResetScenarios 重置场景状态，未指定场景名称时重置所有场景
+func HandleResetScenarios(ctx *fasthttp.RequestCtx, _ func(error)) {
+	res := new(types.ScenarioResetDTO)
+	if len(ctx.Request.Body()) > 0 {
+		if err := bindBody(ctx, res); err != nil {
+			return
+		}
+	}
+
+	application.MockApplication.ResetScenarios(requestContext(ctx), res.Names...)
 	renderSuccessfulResponse(&ctx.Response, nil)
 }
 
+// HandleStartRecording 开始录制，录制期间路径前缀匹配的请求都会转发至上游并录制成规则
+func HandleStartRecording(ctx *fasthttp.RequestCtx, _ func(error)) {
+	res := new(types.RecordingDTO)
+	if len(ctx.Request.Body()) > 0 {
+		if err := bindBody(ctx, res); err != nil {
+			return
+		}
+	}
+
+	session, err := application.MockApplication.StartRecording(requestContext(ctx), res.PathPrefix)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, session)
+}
+
+// HandleStopRecording 停止录制，未指定会话ID时停止当前命名空间中录制中的会话
+func HandleStopRecording(ctx *fasthttp.RequestCtx, _ func(error)) {
+	res := new(types.RecordingDTO)
+	if len(ctx.Request.Body()) > 0 {
+		if err := bindBody(ctx, res); err != nil {
+			return
+		}
+	}
+
+	session, err := application.MockApplication.StopRecording(requestContext(ctx), res.ID)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, session)
+}
+
+// HandleListRecordings 查询当前命名空间的录制会话
+func HandleListRecordings(ctx *fasthttp.RequestCtx, _ func(error)) {
+	renderSuccessfulResponse(&ctx.Response, application.MockApplication.ListRecordings(requestContext(ctx)))
+}
+
+// HandleListRequests 查询请求日志，支持通过query参数rule_id、method、path、since、until、limit筛选
+func HandleListRequests(ctx *fasthttp.RequestCtx, _ func(error)) {
+	query, err := parseJournalQuery(ctx.QueryArgs())
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+
+	entries, err := application.MockApplication.ListRequests(requestContext(ctx), query)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, entries)
+}
+
+// HandleListUnmatchedRequests 查询带有诊断结果的请求日志，即未命中规则或者回落到默认regulation的请求
+func HandleListUnmatchedRequests(ctx *fasthttp.RequestCtx, _ func(error)) {
+	query, err := parseJournalQuery(ctx.QueryArgs())
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	query.Unmatched = true
+
+	entries, err := application.MockApplication.ListRequests(requestContext(ctx), query)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, entries)
+}
+
+// HandleClearRequests 清空请求日志
+func HandleClearRequests(ctx *fasthttp.RequestCtx, _ func(error)) {
+	application.MockApplication.ClearRequests(requestContext(ctx))
+	renderSuccessfulResponse(&ctx.Response, nil)
+}
+
+// HandleVerify 根据请求日志校验请求次数，校验未通过时返回最接近的未匹配请求
+func HandleVerify(ctx *fasthttp.RequestCtx, _ func(error)) {
+	verify := new(types.VerifyDTO)
+	if err := bindBody(ctx, verify); err != nil {
+		return
+	}
+
+	res, err := application.MockApplication.Verify(requestContext(ctx), verify)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, res)
+}
+
+// HandleExplain 演练模拟请求的匹配及渲染过程，不会产生任何副作用
+func HandleExplain(ctx *fasthttp.RequestCtx, _ func(error)) {
+	req := new(types.ExplainRequestDTO)
+	if err := bindBody(ctx, req); err != nil {
+		return
+	}
+
+	res, err := application.MockApplication.Explain(requestContext(ctx), req)
+	if err != nil {
+		renderFailedAPIResponse(&ctx.Response, err)
+		return
+	}
+	renderSuccessfulResponse(&ctx.Response, res)
+}
+
+func parseJournalQuery(args *fasthttp.Args) (*types.JournalQueryDTO, error) {
+	query := &types.JournalQueryDTO{
+		RuleID: string(args.Peek("rule_id")),
+		Method: string(args.Peek("method")),
+		Path:   string(args.Peek("path")),
+	}
+	for key, field := range map[string]**time.Time{"since": &query.Since, "until": &query.Until} {
+		if v := args.Peek(key); len(v) > 0 {
+			t, err := time.Parse(time.RFC3339Nano, string(v))
+			if err != nil {
+				return nil, err
+			}
+			*field = &t
+		}
+	}
+	if v := args.Peek("limit"); len(v) > 0 {
+		limit, err := strconv.Atoi(string(v))
+		if err != nil {
+			return nil, err
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+// HandleMetrics 以Prometheus文本格式输出监控指标
+func HandleMetrics(ctx *fasthttp.RequestCtx, _ func(error)) {
+	metricsHandler(ctx)
+}
+
 // HandleAPIVersion 健康检查用途
 func HandleAPIVersion(ctx *fasthttp.RequestCtx, _ func(error)) {
 	renderSuccessfulResponse(&ctx.Response, "1.0")
 }
 
+// writeContext 修改规则接口的上下文：记录请求头X-Deepmock-User或客户端IP作为修改人；
+// 请求头If-Match指定修改所基于的版本号；解析查询参数wait_for_sync，如 wait_for_sync=5s，修改规则后等待所有实例完成同步
+func writeContext(ctx *fasthttp.RequestCtx) (context.Context, error) {
+	author := string(ctx.Request.Header.Peek(headerUser))
+	if author == "" {
+		author = ctx.RemoteIP().String()
+	}
+	c := application.ContextWithAuthor(requestContext(ctx), author)
+
+	version, ok, err := parseIfMatch(ctx.Request.Header.Peek(fasthttp.HeaderIfMatch))
+	if err != nil {
+		misc.Logger.Error("failed to parse If-Match", zap.ByteString("if_match", ctx.Request.Header.Peek(fasthttp.HeaderIfMatch)), zap.Error(err))
+		renderFailedAPIResponse(&ctx.Response, err)
+		return nil, err
+	}
+	if ok {
+		c = application.ContextWithIfMatch(c, version)
+	}
+
+	v := ctx.QueryArgs().Peek("wait_for_sync")
+	if len(v) == 0 {
+		return c, nil
+	}
+	timeout, err := time.ParseDuration(string(v))
+	if err != nil {
+		misc.Logger.Error("failed to parse wait_for_sync", zap.ByteString("wait_for_sync", v), zap.Error(err))
+		renderFailedAPIResponse(&ctx.Response, err)
+		return nil, err
+	}
+	return application.ContextWithSyncTimeout(c, timeout), nil
+}
+
+// parseIfMatch 解析If-Match请求头中的版本号，支持弱校验前缀W/；为空或者*时不校验
+func parseIfMatch(v []byte) (int, bool, error) {
+	etag := strings.TrimPrefix(strings.TrimSpace(string(v)), "W/")
+	if etag == "" || etag == "*" {
+		return 0, false, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(etag, `"`))
+	if err != nil || version < 0 {
+		return 0, false, errors.New("bad If-Match: " + string(v))
+	}
+	return version, true, nil
+}
+
+// renderRule 返回规则，并以ETag返回规则的版本号
+func renderRule(resp *fasthttp.Response, rule *types.RuleDTO) {
+	resp.Header.Set(fasthttp.HeaderETag, `"`+strconv.Itoa(rule.Version)+`"`)
+	renderSuccessfulResponse(resp, rule)
+}
+
 func bindBody(ctx *fasthttp.RequestCtx, v interface{}) error {
 	if err := json.Unmarshal(ctx.Request.Body(), v); err != nil {
 		misc.Logger.Error("failed to parse request body", zap.ByteString("path", ctx.Request.URI().Path()), zap.ByteString("method", ctx.Request.Header.Method()), zap.Error(err))
@@ -184,7 +578,14 @@ func renderSuccessfulResponse(resp *fasthttp.Response, v interface{}) {
 }
 
 func renderFailedAPIResponse(resp *fasthttp.Response, err error) {
-	res := &types.CommonResponseDTO{Code: http.StatusBadRequest, ErrorMessage: err.Error()}
+	renderFailedAPIResponseWithData(resp, err, nil)
+}
+
+func renderFailedAPIResponseWithData(resp *fasthttp.Response, err error, v interface{}) {
+	res := &types.CommonResponseDTO{Code: http.StatusBadRequest, Data: v, ErrorMessage: err.Error()}
+	if errors.Is(err, domain.ErrVersionConflict) {
+		res.Code = http.StatusConflict
+	}
 	data, _ := json.Marshal(res)
 	resp.Header.SetContentType("application/json")
 	resp.SetBody(data)
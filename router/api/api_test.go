@@ -1,9 +1,14 @@
 package api
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/wosai/deepmock/domain"
+	"github.com/wosai/deepmock/types"
 )
 
 func TestParsePathVar(t *testing.T) {
@@ -12,3 +17,67 @@ func TestParsePathVar(t *testing.T) {
 
 	assert.Equal(t, parsePathVar(path, uri), "123")
 }
+
+func TestParseJournalQuery(t *testing.T) {
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.Parse("rule_id=abc&path=^/pay&since=2022-03-01T08:00:00Z&limit=10")
+
+	query, err := parseJournalQuery(args)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", query.RuleID)
+	assert.Equal(t, "^/pay", query.Path)
+	assert.Equal(t, 10, query.Limit)
+	assert.Nil(t, query.Until)
+	assert.Equal(t, time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC), *query.Since)
+
+	args.Set("until", "yesterday")
+	_, err = parseJournalQuery(args)
+	assert.Error(t, err)
+}
+
+func TestWriteContext(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/v1/rule")
+	_, err := writeContext(ctx)
+	assert.NoError(t, err)
+
+	ctx.Request.SetRequestURI("/api/v1/rule?wait_for_sync=soon")
+	_, err = writeContext(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, string(ctx.Response.Body()), "err_msg")
+
+	ctx.Request.SetRequestURI("/api/v1/rule?wait_for_sync=3s")
+	_, err = writeContext(ctx)
+	assert.NoError(t, err)
+}
+
+func TestParseIfMatch(t *testing.T) {
+	for value, expected := range map[string]int{`"3"`: 3, `W/"12"`: 12, `7`: 7} {
+		version, ok, err := parseIfMatch([]byte(value))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, version)
+	}
+	for _, value := range []string{"", "*"} {
+		_, ok, err := parseIfMatch([]byte(value))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+	for _, value := range []string{`"abc"`, `"-1"`} {
+		_, _, err := parseIfMatch([]byte(value))
+		assert.Error(t, err)
+	}
+}
+
+func TestRenderVersionConflict(t *testing.T) {
+	resp := &fasthttp.Response{}
+	renderRule(resp, &types.RuleDTO{ID: "abc", Version: 2})
+	assert.Equal(t, `"2"`, string(resp.Header.Peek(fasthttp.HeaderETag)))
+
+	renderFailedAPIResponse(resp, &domain.VersionConflictError{RuleID: "abc", Expected: 1})
+	res := new(types.CommonResponseDTO)
+	assert.NoError(t, json.Unmarshal(resp.Body(), res))
+	assert.Equal(t, fasthttp.StatusConflict, res.Code)
+	assert.Equal(t, "rule version conflict: rule abc is not at version 1", res.ErrorMessage)
+}
@@ -9,6 +9,11 @@ import (
 func BuildRouter() *lu.Lu {
 	app := lu.New()
 
+	app.Use("/api", api.HandleTracing)
+
+	app.Post("/api/v1/rule/disable", api.HandleDisableRule)
+	app.Post("/api/v1/rule/enable", api.HandleEnableRule)
+
 	app.Get("/api/v1/rule", api.HandleGetRule)
 	app.Post("/api/v1/rule", api.HandleCreateRule)
 	app.Put("/api/v1/rule", api.HandlePutRule)
@@ -16,10 +21,32 @@ func BuildRouter() *lu.Lu {
 	app.Delete("/api/v1/rule", api.HandleDeleteRule)
 
 	app.Get("/api/version", api.HandleAPIVersion)
+	app.Get("/api/v1/metrics", api.HandleMetrics)
+
+	app.Get("/api/v1/history/revision", api.HandleGetRevision)
+	app.Get("/api/v1/history/diff", api.HandleDiffRevisions)
+	app.Post("/api/v1/history/rollback", api.HandleRollback)
+	app.Get("/api/v1/history", api.HandleListRevisions)
 
 	app.Get("/api/v1/rules", api.HandleExportRules)
 	app.Post("/api/v1/rules", api.HandleImportRules)
 
+	app.Get("/api/v1/scenarios", api.HandleListScenarios)
+	app.Delete("/api/v1/scenarios", api.HandleResetScenarios)
+
+	app.Post("/api/v1/recordings/stop", api.HandleStopRecording)
+	app.Get("/api/v1/recordings", api.HandleListRecordings)
+	app.Post("/api/v1/recordings", api.HandleStartRecording)
+
+	app.Get("/api/v1/requests/unmatched", api.HandleListUnmatchedRequests)
+	app.Get("/api/v1/requests", api.HandleListRequests)
+	app.Delete("/api/v1/requests", api.HandleClearRequests)
+
+	app.Post("/api/v1/verify", api.HandleVerify)
+	app.Post("/api/v1/explain", api.HandleExplain)
+
+	app.Post("/api/v1/sync/wait", api.HandleWaitForSync)
+
 	app.Use("/", api.HandleMockedAPI)
 	return app
 }
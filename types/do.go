@@ -6,14 +6,33 @@ type (
 	// RuleDO Rule在mysql存储结构
 	RuleDO struct {
 		ID        string    `ddb:"id"`
+		Namespace string    `ddb:"namespace"`
 		Path      string    `ddb:"path"`
 		Method    string    `ddb:"method"`
 		Variable  []byte    `ddb:"variable"`
 		Weight    []byte    `ddb:"weight"`
 		Responses []byte    `ddb:"responses"`
+		Priority  int       `ddb:"priority"`
+		Scenario  []byte    `ddb:"scenario"`
 		Version   int       `ddb:"version"`
 		CTime     time.Time `ddb:"ctime"`
 		MTime     time.Time `ddb:"mtime"`
 		Disabled  bool      `ddb:"disabled"`
 	}
+
+	// RuleTombstoneDO 规则删除记录在mysql存储结构，用于增量同步
+	RuleTombstoneDO struct {
+		ID    string    `ddb:"id"`
+		DTime time.Time `ddb:"dtime"`
+	}
+
+	// RuleRevisionDO 规则历史版本在mysql存储结构，content为规则的JSON编码
+	RuleRevisionDO struct {
+		RuleID   string    `ddb:"rule_id"`
+		Revision int       `ddb:"revision"`
+		Action   string    `ddb:"action"`
+		Author   string    `ddb:"author"`
+		Content  []byte    `ddb:"content"`
+		CTime    time.Time `ddb:"ctime"`
+	}
 )
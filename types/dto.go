@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type (
 	// CommonResponseDTO 通用的返回报文结构体
 	CommonResponseDTO struct {
@@ -11,11 +13,16 @@ type (
 	// RuleDTO Rule的HTTP报文结构
 	RuleDTO struct {
 		ID          string           `json:"id,omitempty"`
+		Namespace   string           `json:"namespace,omitempty"`
 		Path        string           `json:"path,omitempty"`
 		Method      string           `json:"method,omitempty"`
 		Variable    VariableDTO      `json:"variable,omitempty"`
 		Weight      WeightDTO        `json:"weight,omitempty"`
 		Regulations []*RegulationDTO `json:"responses,omitempty"`
+		Priority    *int             `json:"priority,omitempty"` // 部分更新时未设置则不修改优先级
+		Scenario    *ScenarioDTO     `json:"scenario,omitempty"`
+		Disabled    bool             `json:"disabled,omitempty"`
+		Version     int              `json:"-"` // 规则版本号，通过ETag返回
 	}
 
 	// VariableDTO 变量的HTTP报文结构
@@ -26,9 +33,23 @@ type (
 
 	// RegulationDTO 响应报文规则的结构
 	RegulationDTO struct {
-		IsDefault bool         `json:"is_default,omitempty"`
-		Filter    *FilterDTO   `json:"filter,omitempty"`
-		Template  *TemplateDTO `json:"response,omitempty"`
+		IsDefault bool            `json:"is_default,omitempty"`
+		Filter    *FilterDTO      `json:"filter,omitempty"`
+		Template  *TemplateDTO    `json:"response,omitempty"`
+		Fault     map[string]uint `json:"fault,omitempty"`
+		Scenario  *ScenarioDTO    `json:"scenario,omitempty"`
+	}
+
+	// ScenarioDTO 场景的HTTP报文结构
+	ScenarioDTO struct {
+		Name          string `json:"name,omitempty"`
+		RequiredState string `json:"required_state,omitempty"`
+		NewState      string `json:"new_state,omitempty"`
+	}
+
+	// ScenarioResetDTO 重置场景状态的HTTP报文结构，names为空时重置所有场景
+	ScenarioResetDTO struct {
+		Names []string `json:"names,omitempty"`
 	}
 
 	// FilterDTO 筛选器的HTTP报文结构
@@ -36,6 +57,9 @@ type (
 		Header map[string]string `json:"header,omitempty"`
 		Query  map[string]string `json:"query,omitempty"`
 		Body   map[string]string `json:"body,omitempty"`
+		AllOf  []*FilterDTO      `json:"all_of,omitempty"`
+		AnyOf  []*FilterDTO      `json:"any_of,omitempty"`
+		Not    *FilterDTO        `json:"not,omitempty"`
 	}
 
 	// TemplateDTO 模板的HTTP报文结构
@@ -47,5 +71,226 @@ type (
 		StatusCode     int               `json:"status_code,omitempty"`
 		Body           string            `json:"body,omitempty"`
 		B64EncodeBody  string            `json:"base64encoded_body,omitempty"`
+		Delay          *DelayDTO         `json:"delay,omitempty"`
+	}
+
+	// DelayDTO 响应延迟的HTTP报文结构，单位均为毫秒
+	DelayDTO struct {
+		Distribution string  `json:"distribution,omitempty"`
+		Fixed        int     `json:"fixed,omitempty"`
+		Min          int     `json:"min,omitempty"`
+		Max          int     `json:"max,omitempty"`
+		Mean         float64 `json:"mean,omitempty"`
+		Stddev       float64 `json:"stddev,omitempty"`
+		Median       float64 `json:"median,omitempty"`
+		Sigma        float64 `json:"sigma,omitempty"`
+		Template     string  `json:"template,omitempty"`
+	}
+
+	// RecordingDTO 录制会话的HTTP报文结构
+	RecordingDTO struct {
+		ID         string     `json:"id,omitempty"`
+		Namespace  string     `json:"namespace,omitempty"`
+		PathPrefix string     `json:"path_prefix,omitempty"`
+		Active     bool       `json:"active"`
+		StartedAt  time.Time  `json:"started_at"`
+		StoppedAt  *time.Time `json:"stopped_at,omitempty"`
+		Rules      []string   `json:"rules,omitempty"`
+	}
+
+	// JournalEntryDTO 请求日志的HTTP报文结构
+	JournalEntryDTO struct {
+		ID              uint64            `json:"id"`
+		Namespace       string            `json:"namespace"`
+		Method          string            `json:"method"`
+		Path            string            `json:"path"`
+		Query           string            `json:"query,omitempty"`
+		Header          map[string]string `json:"header,omitempty"`
+		Body            string            `json:"body,omitempty"`
+		B64EncodeBody   string            `json:"base64encoded_body,omitempty"`
+		RuleID          string            `json:"rule_id,omitempty"`
+		RegulationIndex int               `json:"regulation_index"`
+		Delay           float64           `json:"delay_ms,omitempty"`
+		Fault           string            `json:"fault,omitempty"`
+		Proxied         bool              `json:"proxied,omitempty"`
+		StatusCode      int               `json:"status_code"`
+		ReceivedAt      time.Time         `json:"received_at"`
+		Duration        float64           `json:"duration_ms"`
+		Diagnosis       *DiagnosisDTO     `json:"diagnosis,omitempty"`
+	}
+
+	// JournalQueryDTO 请求日志的查询条件
+	JournalQueryDTO struct {
+		RuleID string     `json:"rule_id,omitempty"`
+		Method string     `json:"method,omitempty"`
+		Path   string     `json:"path,omitempty"` // 正则表达式
+		Since  *time.Time `json:"since,omitempty"`
+		Until  *time.Time `json:"until,omitempty"`
+		Limit  int        `json:"limit,omitempty"`
+
+		Unmatched bool `json:"unmatched,omitempty"`
+	}
+
+	// RequestPatternDTO 请求匹配模式，在筛选器的基础上增加请求方法及路径正则
+	RequestPatternDTO struct {
+		Method string `json:"method,omitempty"`
+		Path   string `json:"path,omitempty"`
+		FilterDTO
+	}
+
+	// CountDTO 请求次数约束，均未设置时表示至少一次
+	CountDTO struct {
+		Exactly *int `json:"exactly,omitempty"`
+		AtLeast *int `json:"at_least,omitempty"`
+		AtMost  *int `json:"at_most,omitempty"`
+	}
+
+	// VerifyDTO 请求校验的HTTP报文结构
+	VerifyDTO struct {
+		RequestPatternDTO
+		Count *CountDTO `json:"count,omitempty"`
+	}
+
+	// NearMissDTO 与匹配模式最接近的未匹配请求
+	NearMissDTO struct {
+		Request    *JournalEntryDTO `json:"request"`
+		Mismatches []string         `json:"mismatches"`
+	}
+
+	// VerifyResultDTO 请求校验结果
+	VerifyResultDTO struct {
+		Passed     bool           `json:"passed"`
+		Count      int            `json:"count"`
+		Expected   string         `json:"expected"`
+		NearMisses []*NearMissDTO `json:"near_misses,omitempty"`
+	}
+
+	// DiagnosisDTO 未命中规则或者回落到默认regulation的诊断结果
+	DiagnosisDTO struct {
+		Fallback   bool           `json:"fallback"`
+		RuleID     string         `json:"rule_id,omitempty"`
+		Summary    string         `json:"summary"`
+		Candidates []*RuleMissDTO `json:"candidates"`
+	}
+
+	// RuleMissDTO 候选规则及未命中的原因
+	RuleMissDTO struct {
+		RuleID      string               `json:"rule_id"`
+		Method      string               `json:"method"`
+		Path        string               `json:"path"`
+		Reasons     []string             `json:"reasons,omitempty"`
+		Regulations []*RegulationMissDTO `json:"regulations,omitempty"`
+	}
+
+	// RegulationMissDTO 候选regulation及未命中的原因
+	RegulationMissDTO struct {
+		Index   int      `json:"index"`
+		Reasons []string `json:"reasons"`
+	}
+
+	// ExplainRequestDTO 用于演练匹配过程的模拟请求，字段与请求日志一致
+	ExplainRequestDTO struct {
+		Method        string            `json:"method"`
+		Path          string            `json:"path"`
+		Query         string            `json:"query,omitempty"`
+		Header        map[string]string `json:"header,omitempty"`
+		Body          string            `json:"body,omitempty"`
+		B64EncodeBody string            `json:"base64encoded_body,omitempty"`
+	}
+
+	// ExplainResultDTO 匹配过程的演练结果
+	ExplainResultDTO struct {
+		Matched         bool                       `json:"matched"`
+		RuleID          string                     `json:"rule_id,omitempty"`
+		RegulationIndex int                        `json:"regulation_index"`
+		Regulations     []*RegulationEvaluationDTO `json:"regulations,omitempty"`
+		Response        *ExplainResponseDTO        `json:"response,omitempty"`
+		Diagnosis       *DiagnosisDTO              `json:"diagnosis,omitempty"`
+	}
+
+	// RegulationEvaluationDTO regulation对请求的筛选结果
+	RegulationEvaluationDTO struct {
+		Index             int      `json:"index"`
+		IsDefault         bool     `json:"is_default"`
+		ScenarioSatisfied bool     `json:"scenario_satisfied"`
+		Matched           bool     `json:"matched"`
+		Chosen            bool     `json:"chosen"`
+		Reasons           []string `json:"reasons,omitempty"`
+	}
+
+	// ExplainResponseDTO 渲染后的响应
+	ExplainResponseDTO struct {
+		StatusCode    int               `json:"status_code"`
+		Header        map[string]string `json:"header,omitempty"`
+		Body          string            `json:"body,omitempty"`
+		B64EncodeBody string            `json:"base64encoded_body,omitempty"`
+		Delay         float64           `json:"delay_ms"`
+		Fault         string            `json:"fault,omitempty"`
+		RenderError   string            `json:"render_error,omitempty"`
+	}
+
+	// SyncTargetDTO 需要等待同步的规则版本，Deleted为true时等待规则被移除
+	SyncTargetDTO struct {
+		ID      string    `json:"id"`
+		Version int       `json:"version"`
+		MTime   time.Time `json:"mtime"`
+		Deleted bool      `json:"deleted,omitempty"`
+	}
+
+	// SyncWaitDTO 等待实例完成规则同步的请求报文，Timeout为Go的时长格式，如 5s
+	SyncWaitDTO struct {
+		Rules   []*SyncTargetDTO `json:"rules"`
+		Timeout string           `json:"timeout"`
+	}
+
+	// RuleRevisionDTO 规则历史版本的HTTP报文结构，Changes为相对上一个历史版本的修改
+	RuleRevisionDTO struct {
+		RuleID   string            `json:"rule_id"`
+		Revision int               `json:"revision"`
+		Version  int               `json:"version"`
+		Action   string            `json:"action"`
+		Author   string            `json:"author,omitempty"`
+		CTime    time.Time         `json:"ctime"`
+		Rule     *RuleDTO          `json:"rule,omitempty"`
+		Changes  []*FieldChangeDTO `json:"changes,omitempty"`
+	}
+
+	// FieldChangeDTO 规则字段的修改，Field为JSON路径，如 responses[0].response.body
+	FieldChangeDTO struct {
+		Field string      `json:"field"`
+		Old   interface{} `json:"old,omitempty"`
+		New   interface{} `json:"new,omitempty"`
+	}
+
+	// RuleDiffDTO 两个历史版本之间的差异
+	RuleDiffDTO struct {
+		RuleID  string            `json:"rule_id"`
+		From    int               `json:"from"`
+		To      int               `json:"to"`
+		Changes []*FieldChangeDTO `json:"changes"`
+	}
+
+	// RollbackDTO 回滚规则的HTTP报文结构
+	RollbackDTO struct {
+		RuleID   string `json:"rule_id"`
+		Revision int    `json:"revision"`
+	}
+
+	// ImportPlanDTO 导入规则的执行计划，Summary为各操作的规则数量
+	ImportPlanDTO struct {
+		Namespace string               `json:"namespace"`
+		Mode      string               `json:"mode"`
+		DryRun    bool                 `json:"dry_run"`
+		Summary   map[string]int       `json:"summary"`
+		Rules     []*ImportPlanItemDTO `json:"rules"`
+	}
+
+	// ImportPlanItemDTO 单个规则的导入计划，Action为updated时Changes为字段修改
+	ImportPlanItemDTO struct {
+		ID      string            `json:"id"`
+		Path    string            `json:"path"`
+		Method  string            `json:"method"`
+		Action  string            `json:"action"`
+		Changes []*FieldChangeDTO `json:"changes,omitempty"`
 	}
 )